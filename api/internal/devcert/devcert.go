@@ -0,0 +1,211 @@
+// Package devcert generates a local CA and a certificate for localhost signed by it, for
+// serving HTTPS in development.
+package devcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Defaults for development certificates.
+const (
+	DefaultDir          = ".devcerts"
+	devCAValidity       = 10 * 365 * 24 * time.Hour
+	devLeafValidity     = 365 * 24 * time.Hour
+	devLeafRenewBefore  = 30 * 24 * time.Hour
+	devCAFileName       = "ca.crt"
+	devCAKeyFileName    = "ca.key"
+	devCertFileName     = "localhost.crt"
+	devCertKeyFileName  = "localhost.key"
+	devCertOrganization = "go-template development"
+)
+
+// devCertHosts are the names the development certificate is valid for.
+var devCertHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// Certs holds the paths to a development CA and a leaf certificate signed by it.
+type Certs struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// Created is true if any of the files were generated, rather than read from the cache.
+	Created bool
+}
+
+// Ensure returns a development CA and a certificate for localhost, 127.0.0.1 and ::1
+// signed by it, cached in dir. The CA is generated on first use and reused after that so that
+// it only has to be trusted once. The certificate is regenerated when it is missing, was not
+// signed by the CA or is about to expire. They must only be used for local development.
+func Ensure(dir string) (Certs, error) {
+	if dir == "" {
+		dir = DefaultDir
+	}
+	certs := Certs{
+		CAFile:   filepath.Join(dir, devCAFileName),
+		CertFile: filepath.Join(dir, devCertFileName),
+		KeyFile:  filepath.Join(dir, devCertKeyFileName),
+	}
+	caKeyFile := filepath.Join(dir, devCAKeyFileName)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return certs, fmt.Errorf("dev certs: %w", err)
+	}
+
+	ca, caKey, err := loadDevCA(certs.CAFile, caKeyFile)
+	if err != nil {
+		if ca, caKey, err = createDevCA(certs.CAFile, caKeyFile); err != nil {
+			return certs, fmt.Errorf("dev certs: %w", err)
+		}
+		certs.Created = true
+	}
+
+	if !certs.Created && validDevLeaf(certs.CertFile, certs.KeyFile, ca) {
+		return certs, nil
+	}
+	if err := createDevLeaf(certs.CertFile, certs.KeyFile, ca, caKey); err != nil {
+		return certs, fmt.Errorf("dev certs: %w", err)
+	}
+	certs.Created = true
+	return certs, nil
+}
+
+// loadDevCA loads the CA certificate and key, it fails if the CA has expired.
+func loadDevCA(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok || !ca.IsCA {
+		return nil, nil, errors.New("not a development CA")
+	}
+	if time.Now().After(ca.NotAfter) {
+		return nil, nil, errors.New("development CA has expired")
+	}
+	return ca, key, nil
+}
+
+// createDevCA generates a CA and writes its certificate and key.
+func createDevCA(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := devSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: devCertOrganization + " CA", Organization: []string{devCertOrganization}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(devCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeDevPair(certFile, keyFile, der, key); err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+// createDevLeaf generates a certificate for devCertHosts signed by ca and writes it and its key.
+func createDevLeaf(certFile, keyFile string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := devSerialNumber()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost", Organization: []string{devCertOrganization}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(devLeafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range devCertHosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	return writeDevPair(certFile, keyFile, der, key)
+}
+
+// validDevLeaf reports if the certificate can be loaded, was signed by ca, is valid for
+// devCertHosts and does not expire within devLeafRenewBefore.
+func validDevLeaf(certFile, keyFile string, ca *x509.Certificate) bool {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return false
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+	if time.Now().Add(devLeafRenewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, host := range devCertHosts {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// writeDevPair writes the PEM encoded certificate der and key, the key is only readable by the owner.
+func writeDevPair(certFile, keyFile string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
+
+// devSerialNumber returns a random serial number.
+func devSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,149 @@
+// Package diagnostics serves the runtime diagnostics of a process, profiles, traces and its
+// runtime configuration, on an admin listener.
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/netip"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"time"
+)
+
+// Defaults for diagnostics configuration.
+const (
+	defaultTraceDuration = time.Second
+	maxTraceDuration     = time.Minute
+	// diagnosticsWriteMargin extends the write deadline of a capture beyond its duration.
+	diagnosticsWriteMargin = 10 * time.Second
+)
+
+// RuntimeInfo is the runtime configuration served by the diagnostics on /debug/runtime.
+type RuntimeInfo struct {
+	GoVersion  string            `json:"goVersion"`
+	GOOS       string            `json:"goos"`
+	GOARCH     string            `json:"goarch"`
+	GOMAXPROCS int               `json:"gomaxprocs"`
+	NumCPU     int               `json:"numCPU"`
+	Goroutines int               `json:"goroutines"`
+	GODEBUG    map[string]string `json:"godebug"`
+}
+
+// Handler returns a handler of the runtime diagnostics of the process, to be
+// served on an admin listener that is not exposed with the application routes:
+//
+//   - /debug/pprof/ serves the profiles of net/http/pprof.
+//   - /debug/trace captures an execution trace for the duration parameter, e.g. ?duration=5s.
+//   - /debug/heap downloads a heap profile, after a garbage collection with ?gc=true.
+//   - /debug/goroutines downloads the stacks of all goroutines.
+//   - /debug/runtime returns the GOMAXPROCS and GODEBUG settings of the process as JSON.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/trace", traceHandler)
+	mux.HandleFunc("GET /debug/heap", heapHandler)
+	mux.HandleFunc("GET /debug/goroutines", goroutinesHandler)
+	mux.HandleFunc("GET /debug/runtime", runtimeHandler)
+	return mux
+}
+
+// traceHandler captures an execution trace for the duration of the request, at most
+// maxTraceDuration. Only one trace can be captured at a time.
+func traceHandler(w http.ResponseWriter, r *http.Request) {
+	duration := defaultTraceDuration
+	if v := r.URL.Query().Get("duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxTraceDuration {
+			http.Error(w, fmt.Sprintf("duration must be between 0 and %s", maxTraceDuration), http.StatusBadRequest)
+			return
+		}
+		duration = d
+	}
+	// the write timeout of the server may be shorter than the capture
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(duration + diagnosticsWriteMargin))
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace.out"`)
+	if err := trace.Start(w); err != nil {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "Failed to start trace: "+err.Error(), http.StatusConflict)
+		return
+	}
+	select {
+	case <-time.After(duration):
+	case <-r.Context().Done():
+	}
+	trace.Stop()
+}
+
+// heapHandler writes a heap profile in the format of pprof.
+func heapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("gc") == "true" {
+		runtime.GC()
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="heap.pprof"`)
+	if err := rpprof.Lookup("heap").WriteTo(w, 0); err != nil {
+		http.Error(w, "Failed to write heap profile: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// goroutinesHandler writes the stacks of all goroutines as text.
+func goroutinesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="goroutines.txt"`)
+	if err := rpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		http.Error(w, "Failed to write goroutines: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// runtimeHandler writes the RuntimeInfo of the process as JSON.
+func runtimeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(readRuntimeInfo())
+}
+
+// readRuntimeInfo returns the RuntimeInfo of the process. GOMAXPROCS is read without
+// changing it, and GODEBUG holds the settings of the environment variable.
+func readRuntimeInfo() RuntimeInfo {
+	info := RuntimeInfo{
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+		GODEBUG:    map[string]string{},
+	}
+	for _, setting := range strings.Split(os.Getenv("GODEBUG"), ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(setting), "="); ok {
+			info.GODEBUG[key] = value
+		}
+	}
+	return info
+}
+
+// LoopbackAddress reports whether the listen address addr, host:port, only accepts
+// connections from the loopback interface.
+func LoopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.Unmap().IsLoopback()
+}
@@ -0,0 +1,311 @@
+// Package levels controls the log levels of a service at runtime, per subsystem and
+// with a time-boxed debug mode, through an admin endpoint or a signal.
+package levels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDebugDuration is how long debug logging lasts when it is toggled, e.g. with SIGUSR1.
+const DefaultDebugDuration = 15 * time.Minute
+
+// Controller controls the log levels of the service at runtime: a default level,
+// overrides for subsystems and a time-boxed debug mode that logs every subsystem at
+// debug level until it reverts. Loggers read their level from it on every record, so
+// changes take effect immediately.
+type Controller struct {
+	base    slog.Level
+	level   slog.LevelVar
+	debug   atomic.Bool
+	mu      sync.Mutex
+	levels  map[string]*subsystemLevel
+	timer   *time.Timer
+	until   time.Time
+	session uint64
+}
+
+// subsystemLevel is the level of a subsystem, the default level unless it is overridden.
+type subsystemLevel struct {
+	c          *Controller
+	level      slog.LevelVar
+	overridden atomic.Bool
+}
+
+// Level returns the level of the subsystem.
+func (l *subsystemLevel) Level() slog.Level {
+	if l.c.debug.Load() {
+		return slog.LevelDebug
+	}
+	if l.overridden.Load() {
+		return l.level.Level()
+	}
+	return l.c.level.Level()
+}
+
+// defaultLevel is the default level of a Controller, as a slog.Leveler.
+type defaultLevel struct {
+	c *Controller
+}
+
+// Level returns the default level.
+func (l defaultLevel) Level() slog.Level {
+	if l.c.debug.Load() {
+		return slog.LevelDebug
+	}
+	return l.c.level.Level()
+}
+
+// NewController returns a Controller with the default level level.
+func NewController(level slog.Level) *Controller {
+	c := &Controller{
+		base:   level,
+		levels: map[string]*subsystemLevel{},
+	}
+	c.level.Set(level)
+	return c
+}
+
+// Level returns the default level, to be set as the level of the handler of a logger.
+func (c *Controller) Level() slog.Leveler {
+	return defaultLevel{c: c}
+}
+
+// Subsystem returns the level of the subsystem name, the default level unless it is overridden.
+func (c *Controller) Subsystem(name string) slog.Leveler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subsystem(name)
+}
+
+// subsystem returns the level of the subsystem name, creating it if needed. c.mu must be held.
+func (c *Controller) subsystem(name string) *subsystemLevel {
+	l, ok := c.levels[name]
+	if !ok {
+		l = &subsystemLevel{c: c}
+		c.levels[name] = l
+	}
+	return l
+}
+
+// Logger returns a logger that passes the records of the subsystem name to the handler
+// of parent at the level of the subsystem, instead of the level of the handler, or at
+// every level if they are logged with a context returned by WithDebug. The records
+// have the attribute subsystem.
+func (c *Controller) Logger(parent *slog.Logger, name string) *slog.Logger {
+	return slog.New(&levelHandler{handler: parent.Handler(), level: c.Subsystem(name)}).With("subsystem", name)
+}
+
+// Set sets the level of the subsystem name, or the default level if name is empty.
+func (c *Controller) Set(name string, level slog.Level) {
+	if name == "" {
+		c.level.Set(level)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := c.subsystem(name)
+	l.level.Set(level)
+	l.overridden.Store(true)
+}
+
+// Reset removes the override of the subsystem name, or restores the default level the
+// Controller was created with if name is empty.
+func (c *Controller) Reset(name string) {
+	if name == "" {
+		c.level.Set(c.base)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.levels[name]; ok {
+		l.overridden.Store(false)
+	}
+}
+
+// DebugFor logs every subsystem at debug level for d, after which the levels revert to
+// those set before. A d of zero or less ends debug logging. It returns when debug
+// logging ends.
+func (c *Controller) DebugFor(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.session++
+	if d <= 0 {
+		c.debug.Store(false)
+		c.until = time.Time{}
+		return c.until
+	}
+	session := c.session
+	c.debug.Store(true)
+	c.until = time.Now().Add(d)
+	c.timer = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// a timer stopped too late must not end a later debug session
+		if c.session == session {
+			c.debug.Store(false)
+			c.until = time.Time{}
+			c.timer = nil
+		}
+	})
+	return c.until
+}
+
+// ToggleDebug ends debug logging if it is enabled, or enables it for d otherwise. It
+// returns when debug logging ends, zero if it was ended.
+func (c *Controller) ToggleDebug(d time.Duration) time.Time {
+	if c.debug.Load() {
+		return c.DebugFor(0)
+	}
+	return c.DebugFor(d)
+}
+
+// levelState is the state of a Controller served by its handler.
+type levelState struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+	DebugUntil *time.Time        `json:"debugUntil,omitempty"`
+}
+
+// levelRequest is the body of a request changing the levels of a Controller.
+type levelRequest struct {
+	// Subsystem is the subsystem to change, the default level if empty.
+	Subsystem string `json:"subsystem"`
+	// Level is the level to set, e.g. "DEBUG" or "warn". If empty the level of the
+	// subsystem is reset.
+	Level string `json:"level"`
+	// DebugFor enables debug logging for the duration, e.g. "10m", or ends it if "0s".
+	DebugFor string `json:"debugFor"`
+}
+
+// state returns the levels of c, with the effective level of each subsystem.
+func (c *Controller) state() levelState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := levelState{
+		Level:      defaultLevel{c: c}.Level().String(),
+		Subsystems: make(map[string]string, len(c.levels)),
+	}
+	names := make([]string, 0, len(c.levels))
+	for name := range c.levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		state.Subsystems[name] = c.levels[name].Level().String()
+	}
+	if !c.until.IsZero() {
+		until := c.until
+		state.DebugUntil = &until
+	}
+	return state
+}
+
+// apply changes the levels of c as described by req.
+func (c *Controller) apply(req levelRequest) error {
+	if req.DebugFor != "" {
+		d, err := time.ParseDuration(req.DebugFor)
+		if err != nil {
+			return fmt.Errorf("invalid debugFor: %w", err)
+		}
+		c.DebugFor(d)
+		if req.Level == "" && req.Subsystem == "" {
+			return nil
+		}
+	}
+	if req.Level == "" {
+		c.Reset(req.Subsystem)
+		return nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		return fmt.Errorf("invalid level: %w", err)
+	}
+	c.Set(req.Subsystem, level)
+	return nil
+}
+
+// Handler returns a handler that serves the levels of c as JSON on GET, and changes
+// them on PUT with a JSON levelRequest, e.g. {"subsystem": "db", "level": "debug"} or
+// {"debugFor": "10m"}. It must be protected, as it lets callers raise the log volume.
+func (c *Controller) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			var req levelRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+				http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := c.apply(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.state())
+	})
+}
+
+// debugContextKey is the key of the debug flag stored in a context.Context.
+type debugContextKey struct{}
+
+// WithDebug returns a copy of ctx with which the records of every subsystem are
+// handled at every level, e.g. for the context of a single request.
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugContextKey{}, true)
+}
+
+// Debug reports whether ctx was returned by WithDebug.
+func Debug(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	debug, _ := ctx.Value(debugContextKey{}).(bool)
+	return debug
+}
+
+// levelHandler is a slog.Handler that handles the records at or above a level,
+// regardless of the level of the handler it wraps, and every record logged with a
+// context returned by WithDebug.
+type levelHandler struct {
+	handler slog.Handler
+	level   slog.Leveler
+}
+
+// Enabled reports whether level is at or above the level of the handler, or ctx was
+// returned by WithDebug.
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return Debug(ctx) || level >= h.level.Level()
+}
+
+// Handle passes r to the handler it wraps.
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a levelHandler wrapping the handler with attrs.
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{handler: h.handler.WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup returns a levelHandler wrapping the handler with the group name.
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{handler: h.handler.WithGroup(name), level: h.level}
+}
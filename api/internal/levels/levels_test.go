@@ -0,0 +1,220 @@
+package levels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestController(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input func(c *Controller)
+		want  map[string]slog.Level
+	}{
+		{
+			name:  "default level",
+			input: func(c *Controller) {},
+			want:  map[string]slog.Level{"": slog.LevelInfo, "db": slog.LevelInfo},
+		},
+		{
+			name: "override subsystem",
+			input: func(c *Controller) {
+				c.Set("db", slog.LevelDebug)
+			},
+			want: map[string]slog.Level{"": slog.LevelInfo, "db": slog.LevelDebug},
+		},
+		{
+			name: "subsystem follows default level",
+			input: func(c *Controller) {
+				c.Set("", slog.LevelWarn)
+			},
+			want: map[string]slog.Level{"": slog.LevelWarn, "db": slog.LevelWarn},
+		},
+		{
+			name: "reset subsystem and default level",
+			input: func(c *Controller) {
+				c.Set("", slog.LevelError)
+				c.Set("db", slog.LevelDebug)
+				c.Reset("")
+				c.Reset("db")
+			},
+			want: map[string]slog.Level{"": slog.LevelInfo, "db": slog.LevelInfo},
+		},
+		{
+			name: "debug for a duration",
+			input: func(c *Controller) {
+				c.Set("db", slog.LevelError)
+				c.DebugFor(time.Hour)
+			},
+			want: map[string]slog.Level{"": slog.LevelDebug, "db": slog.LevelDebug},
+		},
+		{
+			name: "debug ended",
+			input: func(c *Controller) {
+				c.Set("db", slog.LevelError)
+				c.ToggleDebug(time.Hour)
+				c.ToggleDebug(time.Hour)
+			},
+			want: map[string]slog.Level{"": slog.LevelInfo, "db": slog.LevelError},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewController(slog.LevelInfo)
+			test.input(c)
+
+			got := map[string]slog.Level{"": c.Level().Level(), "db": c.Subsystem("db").Level()}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Controller = unexpected levels (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestController_DebugForReverts(t *testing.T) {
+	c := NewController(slog.LevelInfo)
+	c.DebugFor(20 * time.Millisecond)
+	if got := c.Level().Level(); got != slog.LevelDebug {
+		t.Fatalf("Level() = %s; want %s", got, slog.LevelDebug)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for c.Level().Level() != slog.LevelInfo {
+		if time.Now().After(deadline) {
+			t.Fatalf("Level() = %s; want %s after the debug duration", c.Level().Level(), slog.LevelInfo)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if state := c.state(); state.DebugUntil != nil {
+		t.Errorf("state() = debug until %s; want none", state.DebugUntil)
+	}
+}
+
+func TestController_Logger(t *testing.T) {
+	c := NewController(slog.LevelInfo)
+	var buf bytes.Buffer
+	parent := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: c.Level()}))
+	db := c.Logger(parent, "db")
+
+	parent.Debug("parent debug")
+	db.Debug("db debug")
+	db.DebugContext(WithDebug(context.Background()), "db debug context")
+	c.Set("db", slog.LevelDebug)
+	parent.Debug("parent debug")
+	db.Debug("db debug")
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Logger() = invalid JSON %q: %v", line, err)
+		}
+		got = append(got, record["msg"].(string)+" "+record["subsystem"].(string))
+	}
+	if diff := cmp.Diff([]string{"db debug context db", "db debug db"}, got); diff != "" {
+		t.Errorf("Logger() = unexpected records (-want +got):\n%s\n", diff)
+	}
+}
+
+func TestController_Handler(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			method string
+			body   string
+		}
+		want struct {
+			status int
+			state  levelState
+		}
+	}{
+		{
+			name: "get levels",
+			input: struct {
+				method string
+				body   string
+			}{method: "GET"},
+			want: struct {
+				status int
+				state  levelState
+			}{status: http.StatusOK, state: levelState{Level: "INFO", Subsystems: map[string]string{"db": "INFO"}}},
+		},
+		{
+			name: "set level of subsystem",
+			input: struct {
+				method string
+				body   string
+			}{method: "PUT", body: `{"subsystem":"db","level":"debug"}`},
+			want: struct {
+				status int
+				state  levelState
+			}{status: http.StatusOK, state: levelState{Level: "INFO", Subsystems: map[string]string{"db": "DEBUG"}}},
+		},
+		{
+			name: "set default level",
+			input: struct {
+				method string
+				body   string
+			}{method: "PUT", body: `{"level":"WARN"}`},
+			want: struct {
+				status int
+				state  levelState
+			}{status: http.StatusOK, state: levelState{Level: "WARN", Subsystems: map[string]string{"db": "WARN"}}},
+		},
+		{
+			name: "invalid level",
+			input: struct {
+				method string
+				body   string
+			}{method: "PUT", body: `{"level":"verbose"}`},
+			want: struct {
+				status int
+				state  levelState
+			}{status: http.StatusBadRequest},
+		},
+		{
+			name: "invalid debug duration",
+			input: struct {
+				method string
+				body   string
+			}{method: "PUT", body: `{"debugFor":"soon"}`},
+			want: struct {
+				status int
+				state  levelState
+			}{status: http.StatusBadRequest},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewController(slog.LevelInfo)
+			c.Subsystem("db")
+			rec := httptest.NewRecorder()
+			c.Handler().ServeHTTP(rec, httptest.NewRequest(test.input.method, "/loglevel", strings.NewReader(test.input.body)))
+
+			if rec.Code != test.want.status {
+				t.Fatalf("Handler() = status %d; want %d: %s", rec.Code, test.want.status, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var got levelState
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("Handler() = invalid JSON: %v", err)
+			}
+			if diff := cmp.Diff(test.want.state, got); diff != "" {
+				t.Errorf("Handler() = unexpected state (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProbes(t *testing.T) {
+	var tests = []struct {
+		name    string
+		setup   func(p *Probes)
+		handler func(p *Probes) http.Handler
+		target  string
+		status  int
+		body    string
+	}{
+		{
+			name:    "livez",
+			setup:   func(p *Probes) {},
+			handler: (*Probes).LivezHandler,
+			target:  "/livez",
+			status:  http.StatusOK,
+			body:    "ok",
+		},
+		{
+			name:    "livez verbose",
+			setup:   func(p *Probes) {},
+			handler: (*Probes).LivezHandler,
+			target:  "/livez?verbose",
+			status:  http.StatusOK,
+			body:    "[+]ping ok\nlivez check passed\n",
+		},
+		{
+			name:    "readyz before started",
+			setup:   func(p *Probes) {},
+			handler: (*Probes).ReadyzHandler,
+			target:  "/readyz",
+			status:  http.StatusServiceUnavailable,
+			body:    "[-]started failed: not started\n[+]shutdown ok\nreadyz check failed\n",
+		},
+		{
+			name: "readyz with dependency down",
+			setup: func(p *Probes) {
+				p.SetStarted()
+				p.AddReadinessCheck("database", func(ctx context.Context) error { return errors.New("connection refused") })
+			},
+			handler: (*Probes).ReadyzHandler,
+			target:  "/readyz",
+			status:  http.StatusServiceUnavailable,
+			body:    "[+]started ok\n[+]shutdown ok\n[-]database failed: connection refused\nreadyz check failed\n",
+		},
+		{
+			name: "readyz shutting down",
+			setup: func(p *Probes) {
+				p.SetStarted()
+				p.SetShuttingDown()
+			},
+			handler: (*Probes).ReadyzHandler,
+			target:  "/readyz",
+			status:  http.StatusServiceUnavailable,
+			body:    "[+]started ok\n[-]shutdown failed: shutting down\nreadyz check failed\n",
+		},
+		{
+			name: "startupz with startup check",
+			setup: func(p *Probes) {
+				p.SetStarted()
+				p.AddStartupCheck("migrations", func(ctx context.Context) error { return nil })
+			},
+			handler: (*Probes).StartupzHandler,
+			target:  "/startupz?verbose",
+			status:  http.StatusOK,
+			body:    "[+]started ok\n[+]migrations ok\nstartupz check passed\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := New()
+			test.setup(p)
+
+			rr := httptest.NewRecorder()
+			test.handler(p).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			if rr.Code != test.status {
+				t.Errorf("probe status = %d; want %d", rr.Code, test.status)
+			}
+			if diff := cmp.Diff(test.body, rr.Body.String()); diff != "" {
+				t.Errorf("probe body = unexpected result (-want +got):\n%s\n", diff)
+			}
+			if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q; want no-store", got)
+			}
+		})
+	}
+}
+
+func TestProbes_Timeout(t *testing.T) {
+	p := New()
+	p.timeout = 10 * time.Millisecond
+	p.AddLivenessCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	rr := httptest.NewRecorder()
+	p.LivezHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("probe status = %d; want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	want := "[+]ping ok\n[-]slow failed: context deadline exceeded\nlivez check failed\n"
+	if diff := cmp.Diff(want, rr.Body.String()); diff != "" {
+		t.Errorf("probe body = unexpected result (-want +got):\n%s\n", diff)
+	}
+}
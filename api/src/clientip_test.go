@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/go-template/api/internal/clientip"
+)
+
+func TestService_ClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		want           string
+	}{
+		{name: "forwarding headers ignored by default", want: "10.0.0.1"},
+		{name: "trusted proxy", trustedProxies: []string{"10.0.0.0/8"}, want: "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.TrustedProxies = tt.trustedProxies
+			s, err := NewService(cfg)
+			require.NoError(t, err)
+			buf := &syncBuffer{}
+			s.Logger = newLogger(buf, s.Levels, s.Redactor)
+			e, err := s.BindRoutes()
+			require.NoError(t, err)
+
+			var realIP, contextIP string
+			e.GET("/work", func(c echo.Context) error {
+				realIP, contextIP = c.RealIP(), clientip.FromContext(c.Request().Context())
+				s.Logger.InfoContext(c.Request().Context(), "WORK")
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/work", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.7")
+			e.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.want, realIP)
+			assert.Equal(t, tt.want, contextIP)
+			lines := map[string]map[string]any{}
+			for _, line := range buf.Lines() {
+				lines[line["msg"].(string)] = line
+			}
+			require.Contains(t, lines, "WORK")
+			assert.Equal(t, tt.want, lines["WORK"][logKeyClientIP])
+			require.Contains(t, lines, "REQUEST")
+			assert.Equal(t, tt.want, lines["REQUEST"]["request"].(map[string]any)["ip"])
+		})
+	}
+}
+
+func TestNewService_TrustedProxies(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}
+	_, err := NewService(cfg)
+	require.NoError(t, err)
+
+	cfg.TrustedProxies = []string{"proxy.internal"}
+	_, err = NewService(cfg)
+	assert.Error(t, err)
+}
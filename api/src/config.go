@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-playground/validator/v10"
+	"github.com/zate/go-template/api/internal/devcert"
+	"github.com/zate/go-template/api/internal/levels"
+	"github.com/zate/go-template/api/internal/listener"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultConfigPrefix is the prefix of the environment variables read by LoadConfig
+	defaultConfigPrefix = "APP_"
+	// configFileFlag is the flag holding the path to the config file
+	configFileFlag = "config"
+	// configFileKey is the key of the environment variable (<prefix>CONFIG_FILE) holding the path to the config file
+	configFileKey = "config_file"
+)
+
+// Config holds the configuration of the service.
+//
+// Each field is identified by its config tag. Nested structs are separated with a dot in files,
+// and with an underscore in environment variables, which are upper cased and prefixed, e.g.
+// APP_SERVICE_NAME. Flags use a dash, e.g. -service-name. The env tag holds an unprefixed
+// environment variable that is read as a fallback, and fields tagged secret are redacted when logged.
+type Config struct {
+	Environment    string `config:"env" env:"MY_ENVTYPE" validate:"required" usage:"environment the service runs in, e.g. local, dev, staging or prod"`
+	Region         string `config:"region" env:"AWS_REGION" usage:"region the service runs in"`
+	ServiceName    string `config:"service_name" env:"SERVICE_NAME" validate:"required" usage:"name of the service"`
+	ServiceVersion string `config:"service_version" env:"SERVICE_VERSION" usage:"version of the service"`
+	InstanceType   string `config:"instance_type" env:"MY_INSTANCETYPE" usage:"instance type the service runs on"`
+	Port           int    `config:"port" validate:"min=1,max=65535" usage:"port to listen on"`
+	// TrustedProxies are the proxies whose forwarding headers are honored, see clientip.Resolver.
+	TrustedProxies []string `config:"trusted_proxies" validate:"dive,cidr|ip" usage:"networks or IPs of the proxies whose Forwarded, X-Forwarded-For and X-Real-Ip headers are trusted to resolve the client IP"`
+
+	Shutdown ShutdownConfig `config:"shutdown"`
+	TLS      TLSConfig      `config:"tls"`
+	HTTP2    HTTP2Config    `config:"http2"`
+	Tracing  TracingConfig  `config:"tracing"`
+	Log      LogConfig      `config:"log"`
+	Admin    AdminConfig    `config:"admin"`
+
+	RequestDebug RequestDebugConfig `config:"request_debug"`
+	Redaction    RedactionConfig    `config:"redaction"`
+	RateLimit    RateLimitConfig    `config:"rate_limit"`
+}
+
+// ShutdownConfig holds the configuration of the graceful shutdown.
+type ShutdownConfig struct {
+	Timeout time.Duration `config:"timeout" validate:"gt=0" usage:"time to wait for in-flight requests to drain, and separately for the shutdown hooks"`
+	Delay   time.Duration `config:"delay" validate:"gte=0" usage:"time to fail the readiness probe before draining starts"`
+	// RestartTimeout is how long a restart on SIGHUP or SIGUSR2 waits for the new process to be ready.
+	RestartTimeout time.Duration `config:"restart_timeout" validate:"gt=0" usage:"time to wait for the new process to be ready on a restart"`
+}
+
+// TLSConfig holds the configuration of HTTPS. It is served when a certificate is set or Dev is enabled.
+type TLSConfig struct {
+	CertFile   string `config:"cert_file" validate:"required_with=KeyFile" usage:"path to the PEM encoded certificate, enables HTTPS"`
+	KeyFile    string `config:"key_file" validate:"required_with=CertFile" usage:"path to the PEM encoded private key of the certificate"`
+	Dev        bool   `config:"dev" usage:"serve HTTPS with a self-signed development certificate for localhost, only for local development"`
+	DevCertDir string `config:"dev_cert_dir" usage:"directory the development CA and certificate are cached in"`
+}
+
+// HTTP2Config holds the configuration of HTTP/2. Zero values use the defaults of golang.org/x/net/http2.
+type HTTP2Config struct {
+	H2C                  bool `config:"h2c" usage:"serve HTTP/2 without TLS (h2c) alongside HTTP/1.1, e.g. behind a service mesh"`
+	MaxConcurrentStreams int  `config:"max_concurrent_streams" validate:"gte=0" usage:"number of concurrent streams per HTTP/2 connection"`
+	MaxReadFrameSize     int  `config:"max_read_frame_size" validate:"eq=0|min=16384,max=16777215" usage:"largest HTTP/2 frame read, in bytes"`
+}
+
+// TracingConfig holds the configuration of OpenTelemetry tracing.
+type TracingConfig struct {
+	Exporter    string  `config:"exporter" validate:"oneof=none stdout otlp" usage:"where spans are exported: none, stdout or otlp"`
+	Endpoint    string  `config:"endpoint" usage:"host:port of the OTLP/HTTP collector, defaults to OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318"`
+	Insecure    bool    `config:"insecure" usage:"export to the OTLP collector over HTTP instead of HTTPS"`
+	SampleRatio float64 `config:"sample_ratio" validate:"gte=0,lte=1" usage:"ratio of new traces that are sampled, traces started by a caller follow its decision"`
+}
+
+// LogConfig holds the configuration of the log levels, which can be changed at runtime on
+// /loglevel and with SIGUSR1.
+type LogConfig struct {
+	Level         string        `config:"level" validate:"oneof=debug info warn error" usage:"default log level: debug, info, warn or error"`
+	Subsystems    []string      `config:"subsystems" usage:"log levels of subsystems, e.g. checks=debug"`
+	DebugDuration time.Duration `config:"debug_duration" validate:"gt=0" usage:"time debug logging lasts when it is toggled with SIGUSR1"`
+}
+
+// AdminConfig holds the configuration of the admin endpoints, such as /loglevel, and of the
+// admin server of the diagnostics.
+type AdminConfig struct {
+	Token   string `config:"token" secret:"true" usage:"bearer token of the admin endpoints, they are disabled if empty"`
+	Address string `config:"address" validate:"omitempty,hostname_port" usage:"address of the admin server of pprof and runtime diagnostics, e.g. 127.0.0.1:9090, disabled if empty"`
+}
+
+// RequestDebugConfig holds the configuration of per-request debug logging, enabled by the
+// X-Debug header of a request.
+type RequestDebugConfig struct {
+	Secret       string   `config:"secret" secret:"true" usage:"key of the signed X-Debug tokens that enable debug logging for a request"`
+	AllowedCIDRs []string `config:"allowed_cidrs" validate:"dive,cidr" usage:"networks of clients that enable debug logging for a request with any X-Debug header"`
+}
+
+// RedactionConfig holds the configuration of the Redactor that removes sensitive data from
+// the logs and the /debug endpoint. Each list replaces its default when set.
+type RedactionConfig struct {
+	Headers     []string `config:"headers" usage:"names of headers whose values are redacted"`
+	Fields      []string `config:"fields" usage:"paths of JSON body fields whose values are redacted, e.g. user.password, a name without a dot matches the field and log attribute at any depth"`
+	QueryParams []string `config:"query_params" usage:"names of query and form parameters whose values are redacted"`
+	Env         []string `config:"env" usage:"patterns of environment variables whose values are redacted on /debug, e.g. *_TOKEN"`
+	Detectors   []string `config:"detectors" validate:"dive,oneof=email card bearer aws_key" usage:"detectors of values that are redacted anywhere: email, card, bearer or aws_key"`
+	Patterns    []string `config:"patterns" usage:"regular expressions of values that are redacted anywhere"`
+}
+
+// RateLimitConfig holds the rate limits of route groups, see Service.RateLimitMiddleware.
+type RateLimitConfig struct {
+	Groups       []string `config:"groups" usage:"rate limits of route groups as prefix=limit/period, e.g. /users=100/1m, the longest matching prefix applies"`
+	Key          string   `config:"key" validate:"oneof=ip api_key route" usage:"key requests are rate limited by: ip, api_key or route"`
+	APIKeyHeader string   `config:"api_key_header" validate:"required" usage:"header of the API key requests are rate limited by with the api_key key"`
+}
+
+// DefaultConfig returns the Config used before any file, environment variable or flag is applied.
+func DefaultConfig() Config {
+	return Config{
+		Environment:    "local",
+		Region:         "local",
+		ServiceName:    "no_service_name_set",
+		ServiceVersion: "v1",
+		InstanceType:   "local",
+		Port:           8080,
+		Shutdown: ShutdownConfig{
+			Timeout:        15 * time.Second,
+			RestartTimeout: listener.DefaultRestartTimeout,
+		},
+		TLS: TLSConfig{
+			DevCertDir: devcert.DefaultDir,
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracingExporterNone,
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:         "info",
+			DebugDuration: levels.DefaultDebugDuration,
+		},
+		Redaction: DefaultRedactionConfig(),
+		RateLimit: RateLimitConfig{
+			Key:          rateLimitKeyIP,
+			APIKeyHeader: "X-API-Key",
+		},
+	}
+}
+
+// LoadConfig loads the Config from the defaults, an optional YAML, JSON or TOML file, environment
+// variables starting with prefix and the command-line args, in increasing order of precedence.
+// The file is set with the flag -config or the environment variable <prefix>CONFIG_FILE.
+func LoadConfig(prefix string, args []string) (Config, error) {
+	cfg := DefaultConfig()
+	fields := configFields(reflect.ValueOf(&cfg).Elem(), "")
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := fs.String(configFileFlag, os.Getenv(envName(prefix, configFileKey)), "path to a YAML, JSON or TOML config file")
+	flags := make(map[string]*configFlag, len(fields))
+	for _, f := range fields {
+		flags[flagName(f.key)] = &configFlag{field: f}
+		fs.Var(flags[flagName(f.key)], flagName(f.key), f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return cfg, err
+		}
+		if err := checkConfigKeys(values, fields); err != nil {
+			return cfg, fmt.Errorf("config file %s: %w", *configFile, err)
+		}
+		for _, f := range fields {
+			if value, ok := values[f.key]; ok {
+				if err := f.set(value); err != nil {
+					return cfg, fmt.Errorf("config file %s: %w", *configFile, err)
+				}
+			}
+		}
+	}
+
+	for _, f := range fields {
+		value, ok := os.LookupEnv(envName(prefix, f.key))
+		if !ok && f.env != "" {
+			value, ok = os.LookupEnv(f.env)
+		}
+		if !ok {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			return cfg, fmt.Errorf("environment: %w", err)
+		}
+	}
+
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		if cf, ok := flags[fl.Name]; ok && err == nil {
+			err = cf.field.set(cf.value)
+		}
+	})
+	if err != nil {
+		return cfg, fmt.Errorf("flags: %w", err)
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate validates the Config using the validate tags of its fields.
+func (c Config) Validate() error {
+	if err := validator.New().Struct(c); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// LogValue implements slog.LogValuer, it logs the Config with the fields tagged secret redacted.
+func (c Config) LogValue() slog.Value {
+	return configLogValue(reflect.ValueOf(c))
+}
+
+// configLogValue returns the slog.Value of the struct v with the fields tagged secret redacted
+func configLogValue(v reflect.Value) slog.Value {
+	var attrs []slog.Attr
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		key, ok := sf.Tag.Lookup("config")
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		switch {
+		case sf.Type.Kind() == reflect.Struct:
+			attrs = append(attrs, slog.Attr{Key: key, Value: configLogValue(fv)})
+		case sf.Tag.Get("secret") == "true":
+			if fv.IsZero() {
+				attrs = append(attrs, slog.String(key, ""))
+				continue
+			}
+			attrs = append(attrs, slog.String(key, "REDACTED"))
+		default:
+			attrs = append(attrs, slog.Any(key, fv.Interface()))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// configField is a settable field of the Config, identified by its dotted key.
+type configField struct {
+	key   string
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// configFields returns the fields of the struct v, and of the structs nested in it, that have a config tag.
+func configFields(v reflect.Value, prefix string) []configField {
+	var fields []configField
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		key, ok := sf.Tag.Lookup("config")
+		if !ok {
+			continue
+		}
+		key = prefix + key
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, configFields(v.Field(i), key+".")...)
+			continue
+		}
+		fields = append(fields, configField{
+			key:   key,
+			env:   sf.Tag.Get("env"),
+			usage: sf.Tag.Get("usage"),
+			value: v.Field(i),
+		})
+	}
+	return fields
+}
+
+// set converts value to the type of the field and sets it. Strings are parsed, values
+// decoded from a config file are converted, and lists may be given as comma separated strings.
+func (f configField) set(value any) error {
+	switch v := value.(type) {
+	case string:
+		if f.value.Kind() == reflect.Slice {
+			value = splitList(v)
+		}
+	case json.Number, float64:
+		if f.value.Kind() != reflect.Slice {
+			value = f.formatNumber(v)
+		}
+	default:
+		if f.value.Kind() != reflect.Slice {
+			value = fmt.Sprint(value)
+		}
+	}
+
+	var err error
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(value.(string))
+	case bool:
+		var b bool
+		b, err = strconv.ParseBool(value.(string))
+		f.value.SetBool(b)
+	case int, int64:
+		var n int64
+		n, err = strconv.ParseInt(value.(string), 10, 64)
+		f.value.SetInt(n)
+	case float64:
+		var n float64
+		n, err = strconv.ParseFloat(value.(string), 64)
+		f.value.SetFloat(n)
+	case time.Duration:
+		var d time.Duration
+		d, err = time.ParseDuration(value.(string))
+		f.value.SetInt(int64(d))
+	case []string:
+		var list []string
+		switch v := value.(type) {
+		case []string:
+			list = v
+		case []any:
+			for _, item := range v {
+				list = append(list, fmt.Sprint(item))
+			}
+		default:
+			err = fmt.Errorf("expected a list")
+		}
+		f.value.Set(reflect.ValueOf(list))
+	default:
+		err = fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.key, err)
+	}
+	return nil
+}
+
+// formatNumber returns the number n decoded from a config file as a string parsed by the
+// field. An integral float, e.g. 1e+06, is formatted as an integer for an integer field.
+func (f configField) formatNumber(n any) string {
+	s := fmt.Sprint(n)
+	switch f.value.Interface().(type) {
+	case int, int64:
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return s
+		}
+		x, err := strconv.ParseFloat(s, 64)
+		if err == nil && x == math.Trunc(x) && x >= math.MinInt64 && x < math.MaxInt64 {
+			return strconv.FormatInt(int64(x), 10)
+		}
+	}
+	return s
+}
+
+// checkConfigKeys returns an error listing the keys of the values read from a config file
+// that are not keys of fields, e.g. a misspelled key that would otherwise be ignored.
+func checkConfigKeys(values map[string]any, fields []configField) error {
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+	}
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+}
+
+// configFlag is a flag.Value that stores the raw value of a Config field given on the command-line.
+type configFlag struct {
+	field configField
+	value string
+}
+
+// String returns the raw value of the flag
+func (cf *configFlag) String() string {
+	if cf == nil {
+		return ""
+	}
+	return cf.value
+}
+
+// Set stores the raw value of the flag, it is applied after the file and environment
+func (cf *configFlag) Set(value string) error {
+	cf.value = value
+	return nil
+}
+
+// IsBoolFlag allows boolean fields to be set without a value, e.g. -tls
+func (cf *configFlag) IsBoolFlag() bool {
+	return cf.field.value.Kind() == reflect.Bool
+}
+
+// readConfigFile reads the file at path, with the format given by its extension, into a map of dotted keys
+func readConfigFile(path string) (map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &values)
+	case ".json":
+		// numbers are kept as written, see configField.formatNumber
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	case ".toml":
+		err = toml.Unmarshal(b, &values)
+	default:
+		err = errors.New("unsupported config file format, use .yaml, .yml, .json or .toml")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	flat := map[string]any{}
+	flattenConfig(values, "", flat)
+	return flat, nil
+}
+
+// flattenConfig adds the values of the nested map values to flat with their keys joined by dots
+func flattenConfig(values map[string]any, prefix string, flat map[string]any) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]any); ok {
+			flattenConfig(nested, prefix+key+".", flat)
+			continue
+		}
+		flat[prefix+key] = value
+	}
+}
+
+// envName returns the environment variable for key, e.g. APP_SHUTDOWN_TIMEOUT for shutdown.timeout
+func envName(prefix, key string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// flagName returns the flag for key, e.g. shutdown-timeout for shutdown.timeout
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// splitList splits a comma separated list and trims the spaces around each item
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	list := strings.Split(s, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
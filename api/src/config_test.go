@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// unsetFallbackEnv unsets the environment variables read as a fallback by LoadConfig,
+// e.g. AWS_REGION, for the duration of the test.
+func unsetFallbackEnv(t *testing.T) {
+	t.Helper()
+	var cfg Config
+	for _, f := range configFields(reflect.ValueOf(&cfg).Elem(), "") {
+		if f.env == "" {
+			continue
+		}
+		// t.Setenv restores the variable when the test ends
+		t.Setenv(f.env, "")
+		require.NoError(t, os.Unsetenv(f.env))
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	unsetFallbackEnv(t)
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := LoadConfig("TEST_", nil)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), cfg)
+	})
+
+	files := map[string]string{
+		"config.yaml": "env: dev\nservice_name: from-file\nport: 9000\n",
+		"config.json": `{"env": "dev", "service_name": "from-file", "port": 9000}`,
+		"config.toml": "env = \"dev\"\nservice_name = \"from-file\"\nport = 9000\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfigFile(t, name, content)
+			cfg, err := LoadConfig("TEST_", []string{"-config", path})
+			require.NoError(t, err)
+			assert.Equal(t, "dev", cfg.Environment)
+			assert.Equal(t, "from-file", cfg.ServiceName)
+			assert.Equal(t, 9000, cfg.Port)
+			assert.Equal(t, "local", cfg.Region)
+		})
+	}
+
+	t.Run("precedence", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "env: dev\nservice_name: from-file\nport: 9000\nregion: file-region\n")
+		t.Setenv("TEST_CONFIG_FILE", path)
+		t.Setenv("TEST_SERVICE_NAME", "from-env")
+		t.Setenv("TEST_PORT", "9001")
+		t.Setenv("AWS_REGION", "eu-west-1")
+
+		cfg, err := LoadConfig("TEST_", []string{"-port", "9002"})
+		require.NoError(t, err)
+		assert.Equal(t, "dev", cfg.Environment, "file should override defaults")
+		assert.Equal(t, "from-env", cfg.ServiceName, "environment should override file")
+		assert.Equal(t, "eu-west-1", cfg.Region, "fallback environment variable should override file")
+		assert.Equal(t, 9002, cfg.Port, "flags should override environment")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := LoadConfig("TEST_", []string{"-port", "70000"})
+		assert.ErrorContains(t, err, "invalid config")
+
+		_, err = LoadConfig("TEST_", []string{"-port", "http"})
+		assert.ErrorContains(t, err, "port")
+
+		_, err = LoadConfig("TEST_", []string{"-config", writeConfigFile(t, "config.ini", "")})
+		assert.ErrorContains(t, err, "unsupported config file format")
+
+		_, err = LoadConfig("TEST_", []string{"-config", writeConfigFile(t, "config.yaml", "prot: 9000\ntls:\n  cert: cert.pem\n")})
+		assert.ErrorContains(t, err, "unknown keys: prot, tls.cert")
+
+		_, err = LoadConfig("TEST_", []string{"-config", writeConfigFile(t, "config.json", `{"port": 9000.5}`)})
+		assert.ErrorContains(t, err, "port")
+	})
+
+	numbers := map[string]string{
+		"config.yaml": "port: 1.0e+4\nhttp2:\n  max_read_frame_size: 16384.0\ntracing:\n  sample_ratio: 0.5\n",
+		"config.json": `{"port": 1e+04, "http2": {"max_read_frame_size": 16384}, "tracing": {"sample_ratio": 5e-1}}`,
+		"config.toml": "port = 1e4\ntracing.sample_ratio = 0.5\n[http2]\nmax_read_frame_size = 16384\n",
+	}
+	for name, content := range numbers {
+		t.Run("numbers "+name, func(t *testing.T) {
+			cfg, err := LoadConfig("TEST_", []string{"-config", writeConfigFile(t, name, content)})
+			require.NoError(t, err)
+			assert.Equal(t, 10000, cfg.Port)
+			assert.Equal(t, 16384, cfg.HTTP2.MaxReadFrameSize)
+			assert.Equal(t, 0.5, cfg.Tracing.SampleRatio)
+		})
+	}
+}
+
+func TestConfigLogValue(t *testing.T) {
+	type nested struct {
+		Token string `config:"token" secret:"true"`
+		Empty string `config:"empty" secret:"true"`
+	}
+	cfg := struct {
+		Name   string `config:"name"`
+		Nested nested `config:"nested"`
+	}{
+		Name:   "api",
+		Nested: nested{Token: "s3cr3t"},
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})).Info("STARTING_SERVICE", slog.Any("config", configLogValue(reflect.ValueOf(cfg))))
+
+	assert.Equal(t, "level=INFO msg=STARTING_SERVICE config.name=api config.nested.token=REDACTED config.nested.empty=\"\"\n", buf.String())
+	assert.NotContains(t, buf.String(), "s3cr3t")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/zate/go-template/api/internal/levels"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers of per-request debug logging.
+const (
+	// headerDebug enables debug logging for a request, with a token signed by SignDebugToken,
+	// or with any value from a client allowed by RequestDebugConfig.AllowedCIDRs.
+	headerDebug = "X-Debug"
+	// headerDebugID is set on the response of a debug request to the ID its log records can be found by.
+	headerDebugID = "X-Debug-Id"
+)
+
+// SignDebugToken returns a token for the X-Debug header that enables debug logging for
+// requests until expires, signed with secret.
+func SignDebugToken(secret string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + debugTokenSignature(secret, exp)
+}
+
+// debugTokenSignature returns the hex encoded HMAC-SHA256 of exp with secret.
+func debugTokenSignature(secret, exp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyDebugToken reports whether token was signed with secret and has not expired at now.
+func verifyDebugToken(secret, token string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(debugTokenSignature(secret, exp)))
+}
+
+// DebugMiddleware returns a middleware that enables debug logging for requests with a valid
+// X-Debug header, without changing the levels of other requests. The records logged with
+// the context of such a request are logged at every level, with the debug and caller
+// attributes, and its request log has the request and response headers. The response has
+// the X-Debug-Id header, the trace ID its records are logged with.
+func (s *Service) DebugMiddleware() echo.MiddlewareFunc {
+	var allowed []netip.Prefix
+	for _, cidr := range s.Config.RequestDebug.AllowedCIDRs {
+		// validated by Config.Validate
+		if prefix, err := netip.ParsePrefix(cidr); err == nil {
+			allowed = append(allowed, prefix)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			token := req.Header.Get(headerDebug)
+			if token == "" {
+				return next(c)
+			}
+			if !verifyDebugToken(s.Config.RequestDebug.Secret, token, time.Now()) && !allowedClient(allowed, s.clientIP(c)) {
+				return next(c)
+			}
+
+			ctx := levels.WithDebug(req.Context())
+			c.SetRequest(req.WithContext(ctx))
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+				c.Response().Header().Set(headerDebugID, sc.TraceID().String())
+			}
+			return next(c)
+		}
+	}
+}
+
+// allowedClient reports whether the client address addr is in one of the prefixes allowed.
+// Forwarding headers are only trusted from the proxies of Config.TrustedProxies.
+func allowedClient(allowed []netip.Prefix, addr string) bool {
+	if len(allowed) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range allowed {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_DebugMiddleware(t *testing.T) {
+	const secret = "debug-secret"
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		wantDebug  bool
+	}{
+		{name: "no header", remoteAddr: "192.0.2.10:1234"},
+		{name: "signed token", remoteAddr: "192.0.2.10:1234", header: SignDebugToken(secret, time.Now().Add(time.Minute)), wantDebug: true},
+		{name: "expired token", remoteAddr: "192.0.2.10:1234", header: SignDebugToken(secret, time.Now().Add(-time.Minute))},
+		{name: "token signed with another secret", remoteAddr: "192.0.2.10:1234", header: SignDebugToken("guess", time.Now().Add(time.Minute))},
+		{name: "allowed client", remoteAddr: "10.1.2.3:1234", header: "1", wantDebug: true},
+		{name: "client not allowed", remoteAddr: "192.0.2.10:1234", header: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, buf := newTestService(t)
+			s.Config.RequestDebug = RequestDebugConfig{Secret: secret, AllowedCIDRs: []string{"10.0.0.0/8"}}
+			e, err := s.BindRoutes()
+			require.NoError(t, err)
+			e.GET("/work", func(c echo.Context) error {
+				s.Logger.DebugContext(c.Request().Context(), "DETAIL")
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/work", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set(headerDebug, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			lines := map[string]map[string]any{}
+			for _, line := range buf.Lines() {
+				lines[line["msg"].(string)] = line
+			}
+			require.Contains(t, lines, "REQUEST")
+			if !tt.wantDebug {
+				assert.NotContains(t, lines, "DETAIL", "debug records should not be logged")
+				assert.Empty(t, rec.Header().Get(headerDebugID))
+				assert.Nil(t, lines["REQUEST"]["debug"])
+				return
+			}
+
+			require.Contains(t, lines, "DETAIL", "debug records of the request should be logged")
+			assert.Equal(t, true, lines["DETAIL"]["debug"])
+			assert.Contains(t, lines["DETAIL"]["filename"], "debug_request_test.go")
+			assert.NotEmpty(t, lines["DETAIL"]["line"])
+			assert.Equal(t, lines["DETAIL"]["trace-id"], rec.Header().Get(headerDebugID), "debug ID should find the records of the request")
+			assert.Equal(t, lines["REQUEST"]["trace-id"], rec.Header().Get(headerDebugID))
+			assert.Equal(t, true, lines["REQUEST"]["debug"])
+
+			// other requests are not affected
+			logged := len(buf.Lines())
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/work", nil))
+			for _, line := range buf.Lines()[logged:] {
+				assert.NotEqual(t, "DETAIL", line["msg"])
+			}
+		})
+	}
+}
+
+func TestVerifyDebugToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token := SignDebugToken("secret", now.Add(time.Minute))
+
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		want   bool
+	}{
+		{name: "valid", secret: "secret", token: token, want: true},
+		{name: "without secret", secret: "", token: SignDebugToken("", now.Add(time.Minute))},
+		{name: "malformed", secret: "secret", token: "abc"},
+		{name: "tampered expiry", secret: "secret", token: "9999999999" + token[len("1700000060"):]},
+		{name: "expired", secret: "secret", token: SignDebugToken("secret", now.Add(-time.Second))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, verifyDebugToken(tt.secret, tt.token, now))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_AdminServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		address    string
+		token      string
+		header     string
+		wantStatus int
+		wantErr    string
+	}{
+		{name: "disabled without address"},
+		{name: "loopback address", address: "127.0.0.1:9090", wantStatus: http.StatusOK},
+		{name: "public address without token", address: ":9090", wantErr: "admin server: :9090 is not a loopback address, admin.token is required"},
+		{name: "wrong token", address: ":9090", token: "secret", header: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "admin token", address: "0.0.0.0:9090", token: "secret", header: "Bearer secret", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t)
+			s.Config.Admin = AdminConfig{Address: tt.address, Token: tt.token}
+			admin, err := s.adminServer()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.address == "" {
+				assert.Nil(t, admin)
+				return
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+			req.Header.Set("Authorization", tt.header)
+			rec := httptest.NewRecorder()
+			admin.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestService_AdminServerLifecycle(t *testing.T) {
+	s, _, _ := newTestService(t)
+	s.Config.Admin.Address = fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	url, runErr := runTestService(t, s)
+	adminURL := "http://" + s.Config.Admin.Address
+
+	resp, err := http.Get(adminURL + "/debug/pprof/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the diagnostics are not served with the application routes
+	resp, err = http.Get(url + "/debug/pprof/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEqual(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	s.Stop()
+	select {
+	case err := <-runErr:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	_, err = http.Get(adminURL + "/debug/runtime")
+	assert.Error(t, err, "admin server should be shut down with the service")
+}
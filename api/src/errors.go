@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
-	"errors"
 	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 
 	"net/http"
 
@@ -11,47 +14,133 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
-var ()
+const (
+	// MIMEApplicationProblemJSON is the content type of RFC 7807 problem details responses
+	MIMEApplicationProblemJSON = "application/problem+json"
+	// defaultProblemType is the problem type used when no more specific type is available, see RFC 7807 section 4.2
+	defaultProblemType = "about:blank"
+)
 
 type (
 	httpErrorHandler struct {
-		statusCodes map[error]int
+		mu         sync.RWMutex
+		registered []registeredError
+	}
+
+	// registeredError is a sentinel error and the HTTP status code it is answered with
+	registeredError struct {
+		err    error
+		status int
+	}
+
+	// Problem is an RFC 7807 problem details response. Code is the name of the sentinel error,
+	// or the status text without spaces, and is stable for clients to match on.
+	Problem struct {
+		Type      string `json:"type"`
+		Title     string `json:"title"`
+		Status    int    `json:"status"`
+		Detail    string `json:"detail,omitempty"`
+		Instance  string `json:"instance,omitempty"`
+		RequestID string `json:"request_id,omitempty"`
+		Code      string `json:"code"`
 	}
 )
 
+// NewHttpErrorHandler returns an error handler with the sentinel errors of errorStatusCodeMaps
+// registered in the order of their codes, so that the handler does not depend on the order of the map.
 func NewHttpErrorHandler(errorStatusCodeMaps map[error]int) *httpErrorHandler {
-	return &httpErrorHandler{
-		statusCodes: errorStatusCodeMaps,
+	sentinels := make([]error, 0, len(errorStatusCodeMaps))
+	for err := range errorStatusCodeMaps {
+		sentinels = append(sentinels, err)
+	}
+	sort.Slice(sentinels, func(i, j int) bool {
+		return sentinels[i].Error() < sentinels[j].Error()
+	})
+
+	eh := &httpErrorHandler{}
+	for _, err := range sentinels {
+		eh.Register(err, errorStatusCodeMaps[err])
 	}
+	return eh
 }
 
-func (eh *httpErrorHandler) getStatusCode(err error) int {
-	for key, value := range eh.statusCodes {
-		if errors.Is(err, key) {
-			return value
+// Register maps the sentinel error err to the HTTP status code status.
+// Errors wrapping err are answered with status and the code err.Error().
+// Registering err again replaces its status code but keeps its place in the registration order.
+func (eh *httpErrorHandler) Register(err error, status int) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	for i := range eh.registered {
+		if eh.registered[i].err == err {
+			eh.registered[i].status = status
+			return
 		}
 	}
+	eh.registered = append(eh.registered, registeredError{err: err, status: status})
+}
+
+func (eh *httpErrorHandler) getStatusCode(err error) int {
+	if sentinel, ok := eh.getSentinel(err); ok {
+		return sentinel.status
+	}
 
 	return http.StatusInternalServerError
 }
 
-func unwrapRecursive(err error) error {
-	var originalErr = err
+// getSentinel returns the registered sentinel error that err matches, and whether there is one.
+// The errors in the chain of err are visited in the order of errors.Is, and the first one that
+// matches a sentinel decides, so the sentinel wrapped most closely by err wins. A single error
+// matching several sentinels, through an Is method, matches the one registered first.
+func (eh *httpErrorHandler) getSentinel(err error) (registeredError, bool) {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
 
-	for originalErr != nil {
-		var internalErr = errors.Unwrap(originalErr)
+	return eh.match(err)
+}
+
+// match returns the registered error that err, or else an error it wraps, matches.
+func (eh *httpErrorHandler) match(err error) (registeredError, bool) {
+	if err == nil {
+		return registeredError{}, false
+	}
+	for _, r := range eh.registered {
+		if matchesSentinel(err, r.err) {
+			return r, true
+		}
+	}
 
-		if internalErr == nil {
-			break
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		return eh.match(x.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range x.Unwrap() {
+			if r, ok := eh.match(wrapped); ok {
+				return r, true
+			}
 		}
+	}
 
-		originalErr = internalErr
+	return registeredError{}, false
+}
+
+// matchesSentinel reports whether err itself, without the errors it wraps, matches target like errors.Is does
+func matchesSentinel(err, target error) bool {
+	if reflect.TypeOf(target).Comparable() && err == target {
+		return true
 	}
+	x, ok := err.(interface{ Is(error) bool })
+	return ok && x.Is(target)
+}
 
-	return originalErr
+// statusCode turns an HTTP status code into a code by removing the spaces from its status text, e.g. NotFound
+func statusCode(status int) string {
+	return strings.ReplaceAll(http.StatusText(status), " ", "")
 }
 
-func (eh *httpErrorHandler) Handler(err error, c echo.Context) {
+// NewProblem creates the Problem for err in the context of the request in c
+func (eh *httpErrorHandler) NewProblem(err error, c echo.Context) Problem {
+	var problem Problem
+
 	he, ok := err.(*echo.HTTPError)
 	if ok {
 		if he.Internal != nil {
@@ -59,25 +148,47 @@ func (eh *httpErrorHandler) Handler(err error, c echo.Context) {
 				he = herr
 			}
 		}
-	} else {
-		he = &echo.HTTPError{
-			Code:    eh.getStatusCode(err),
-			Message: unwrapRecursive(err).Error(),
+		problem.Status = he.Code
+		problem.Code = statusCode(he.Code)
+		if sentinel, ok := eh.getSentinel(he.Internal); ok {
+			problem.Code = sentinel.err.Error()
 		}
+		if msg, ok := he.Message.(string); ok {
+			problem.Detail = msg
+		} else if he.Message != nil {
+			problem.Detail = http.StatusText(he.Code)
+		}
+	} else if sentinel, ok := eh.getSentinel(err); ok {
+		problem.Status = sentinel.status
+		problem.Code = sentinel.err.Error()
+		// The errors wrapping the sentinel add internal context, so only its text is exposed
+		problem.Detail = sentinel.err.Error()
+	} else {
+		// Unmapped errors are internal, so their details are not exposed
+		problem.Status = http.StatusInternalServerError
+		problem.Code = ErrInternalServiceError.Error()
 	}
 
-	code := he.Code
-	message := he.Message
-	if _, ok := he.Message.(string); ok {
-		message = map[string]interface{}{"message": err.Error()}
+	problem.Type = defaultProblemType
+	problem.Title = http.StatusText(problem.Status)
+	problem.Instance = c.Request().URL.RequestURI()
+	problem.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if problem.RequestID == "" {
+		problem.RequestID = c.Request().Header.Get(echo.HeaderXRequestID)
 	}
+	return problem
+}
+
+func (eh *httpErrorHandler) Handler(err error, c echo.Context) {
+	problem := eh.NewProblem(err, c)
 
 	// Send response
 	if !c.Response().Committed {
 		if c.Request().Method == http.MethodHead {
-			err = c.NoContent(he.Code)
+			err = c.NoContent(problem.Status)
 		} else {
-			err = c.JSON(code, message)
+			c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+			err = c.JSON(problem.Status, problem)
 		}
 		if err != nil {
 			c.Echo().Logger.Error(err)
@@ -23,6 +23,8 @@ var (
 	ErrTooManyRequests      = errors.New("TooManyRequests")
 )
 
+// NewErrorStatusCodeMaps returns the default mapping of sentinel errors to HTTP status codes.
+// Service specific errors should be added with Service.RegisterError rather than here.
 func NewErrorStatusCodeMaps() map[error]int {
 
 	var errorStatusCodeMaps = make(map[error]int)
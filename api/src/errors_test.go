@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errQuotaExceeded = errors.New("QuotaExceeded")
+	// errPlanLimit is a service error that wraps a generic one
+	errPlanLimit = fmt.Errorf("PlanLimit: %w", ErrForbidden)
+)
+
+func TestHttpErrorHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		handler echo.HandlerFunc
+		want    Problem
+	}{
+		{
+			name:   "wrapped sentinel error",
+			method: http.MethodGet,
+			target: "/documents/1?x=y",
+			handler: func(c echo.Context) error {
+				return fmt.Errorf("document %s: %w", c.Param("id"), ErrDocumentNotFound)
+			},
+			want: Problem{
+				Type:      defaultProblemType,
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "DocumentNotFound",
+				Instance:  "/documents/1?x=y",
+				RequestID: "req-1",
+				Code:      "DocumentNotFound",
+			},
+		},
+		{
+			name:   "registered sentinel error",
+			method: http.MethodGet,
+			target: "/documents/2",
+			handler: func(c echo.Context) error {
+				return errQuotaExceeded
+			},
+			want: Problem{
+				Type:      defaultProblemType,
+				Title:     "Payment Required",
+				Status:    http.StatusPaymentRequired,
+				Detail:    "QuotaExceeded",
+				Instance:  "/documents/2",
+				RequestID: "req-1",
+				Code:      "QuotaExceeded",
+			},
+		},
+		{
+			name:   "http error with sentinel internal",
+			method: http.MethodGet,
+			target: "/documents/3",
+			handler: func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusTooManyRequests, "slow down").SetInternal(ErrTooManyRequests)
+			},
+			want: Problem{
+				Type:      defaultProblemType,
+				Title:     "Too Many Requests",
+				Status:    http.StatusTooManyRequests,
+				Detail:    "slow down",
+				Instance:  "/documents/3",
+				RequestID: "req-1",
+				Code:      "TooManyRequests",
+			},
+		},
+		{
+			name:   "http error",
+			method: http.MethodGet,
+			target: "/documents/4",
+			handler: func(c echo.Context) error {
+				return echo.ErrMethodNotAllowed
+			},
+			want: Problem{
+				Type:      defaultProblemType,
+				Title:     "Method Not Allowed",
+				Status:    http.StatusMethodNotAllowed,
+				Detail:    "Method Not Allowed",
+				Instance:  "/documents/4",
+				RequestID: "req-1",
+				Code:      "MethodNotAllowed",
+			},
+		},
+		{
+			name:   "unmapped error",
+			method: http.MethodGet,
+			target: "/documents/5",
+			handler: func(c echo.Context) error {
+				return errors.New("password=hunter2 rejected by database")
+			},
+			want: Problem{
+				Type:      defaultProblemType,
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Instance:  "/documents/5",
+				RequestID: "req-1",
+				Code:      "InternalServiceError",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, e, _ := newTestService(t)
+			s.RegisterError(errQuotaExceeded, http.StatusPaymentRequired)
+			e.GET("/documents/:id", tt.handler)
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Header.Set(echo.HeaderXRequestID, "req-1")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want.Status, rec.Code)
+			assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+			var got Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHttpErrorHandler_MultipleSentinels(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "joined sentinels match the first one",
+			err:        errors.Join(errQuotaExceeded, ErrDocumentNotFound),
+			wantStatus: http.StatusPaymentRequired,
+			wantCode:   "QuotaExceeded",
+		},
+		{
+			name:       "sentinels wrapped by one error match the first one",
+			err:        fmt.Errorf("document 1: %w: %w", ErrDocumentNotFound, errQuotaExceeded),
+			wantStatus: http.StatusNotFound,
+			wantCode:   "DocumentNotFound",
+		},
+		{
+			name:       "the sentinel wrapped most closely wins",
+			err:        fmt.Errorf("upload: %w", errPlanLimit),
+			wantStatus: http.StatusPaymentRequired,
+			wantCode:   "PlanLimit: Forbidden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eh := NewHttpErrorHandler(NewErrorStatusCodeMaps())
+			eh.Register(errQuotaExceeded, http.StatusPaymentRequired)
+			eh.Register(errPlanLimit, http.StatusPaymentRequired)
+
+			// the result must not depend on the order the sentinels are visited in
+			for i := 0; i < 20; i++ {
+				sentinel, ok := eh.getSentinel(tt.err)
+				require.True(t, ok)
+				assert.Equal(t, tt.wantCode, sentinel.err.Error())
+				assert.Equal(t, tt.wantStatus, eh.getStatusCode(tt.err))
+			}
+		})
+	}
+}
+
+func TestHttpErrorHandler_Head(t *testing.T) {
+	_, e, _ := newTestService(t)
+	e.HEAD("/documents/:id", func(c echo.Context) error {
+		return ErrDocumentNotFound
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/documents/1", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
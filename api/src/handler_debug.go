@@ -1,38 +1,206 @@
 package main
 
 import (
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"runtime"
+	"runtime/debug"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"golang.org/x/exp/slices"
 )
 
-// TODO: Set this to only allow debug requests from localhost and only when running locally under comtek/air
+// debugEnvironments are the environments /debug is registered in.
+var debugEnvironments = []string{"local", "dev"}
+
+// DebugReport is the diagnostics report served by the /debug endpoint.
+type DebugReport struct {
+	Build       *BuildInfo        `json:"build,omitempty"`
+	StartedAt   time.Time         `json:"started_at"`
+	Uptime      string            `json:"uptime"`
+	Goroutines  int               `json:"goroutines"`
+	Memory      MemoryInfo        `json:"memory"`
+	Config      map[string]any    `json:"config"`
+	Routes      []RouteInfo       `json:"routes"`
+	Environment map[string]string `json:"environment"`
+}
+
+// BuildInfo holds the build information of the binary, see debug.ReadBuildInfo.
+type BuildInfo struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Version   string            `json:"version"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+// MemoryInfo holds the memory statistics of the Go runtime, in bytes.
+type MemoryInfo struct {
+	Alloc       uint64 `json:"alloc"`
+	TotalAlloc  uint64 `json:"total_alloc"`
+	Sys         uint64 `json:"sys"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapObjects uint64 `json:"heap_objects"`
+	NumGC       uint32 `json:"num_gc"`
+}
+
+// RouteInfo is a route registered on the server.
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// debugEnabled reports whether /debug is registered in the environment of the service
+func (s *Service) debugEnabled() bool {
+	return slices.Contains(debugEnvironments, s.Config.Environment)
+}
+
+// debugAuth returns a middleware that allows requests from the loopback interface, and
+// requires the admin token of other requests. Forwarding headers are only trusted from
+// the proxies of Config.TrustedProxies.
+func (s *Service) debugAuth() echo.MiddlewareFunc {
+	adminAuth := s.adminAuth()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := adminAuth(next)
+		return func(c echo.Context) error {
+			if loopbackClient(s.clientIP(c)) {
+				return next(c)
+			}
+			if s.Config.Admin.Token == "" {
+				return echo.ErrForbidden
+			}
+			return authenticated(c)
+		}
+	}
+}
+
+// loopbackClient reports whether the client address addr is a loopback address
+func loopbackClient(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.Unmap().IsLoopback()
+}
 
 // DebugHandler is a function that handles requests to the /debug endpoint.
 func (s *Service) DebugHandler(c echo.Context) error {
+	return c.JSONPretty(http.StatusOK, s.DebugReport(c.Echo()), "  ")
+}
+
+// DebugReport returns the diagnostics report of the service with the routes of e. The
+// config and environment are redacted.
+func (s *Service) DebugReport(e *echo.Echo) DebugReport {
 	env := os.Environ()
 	// sort the []string alphabetically using slices.Sort
 	slices.Sort(sort.StringSlice(env))
 
-	payload := s.GenDebugInfo(env)
-	return c.JSONPretty(http.StatusOK, payload, "  ")
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return DebugReport{
+		Build:      readBuildInfo(),
+		StartedAt:  s.started,
+		Uptime:     time.Since(s.started).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: MemoryInfo{
+			Alloc:       mem.Alloc,
+			TotalAlloc:  mem.TotalAlloc,
+			Sys:         mem.Sys,
+			HeapInuse:   mem.HeapInuse,
+			HeapObjects: mem.HeapObjects,
+			NumGC:       mem.NumGC,
+		},
+		Config:      s.debugConfig(),
+		Routes:      debugRoutes(e),
+		Environment: s.GenDebugInfo(env),
+	}
+}
+
+// readBuildInfo returns the build information of the binary, or nil if it is not available
+func readBuildInfo() *BuildInfo {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	info := &BuildInfo{
+		GoVersion: bi.GoVersion,
+		Path:      bi.Path,
+		Version:   bi.Main.Version,
+		Settings:  make(map[string]string),
+	}
+	for _, setting := range bi.Settings {
+		info.Settings[setting.Key] = setting.Value
+	}
+	return info
 }
 
-// GenDebugInfo is a function that turns a []string of key=value pairs into a map[string]string
+// debugConfig returns the config of the service, with the fields tagged secret and the
+// values matched by the Redactor redacted
+func (s *Service) debugConfig() map[string]any {
+	return attrMap(s.redactor().Attr(slog.Any("config", s.Config)).Value)
+}
+
+// attrMap returns the group value v as a map
+func attrMap(v slog.Value) map[string]any {
+	m := make(map[string]any)
+	for _, attr := range v.Group() {
+		switch attr.Value.Kind() {
+		case slog.KindGroup:
+			m[attr.Key] = attrMap(attr.Value)
+		case slog.KindDuration:
+			m[attr.Key] = attr.Value.Duration().String()
+		default:
+			m[attr.Key] = attr.Value.Any()
+		}
+	}
+	return m
+}
+
+// debugRoutes returns the routes registered on e, sorted by path and method
+func debugRoutes(e *echo.Echo) []RouteInfo {
+	var routes []RouteInfo
+	for _, r := range e.Routes() {
+		if r.Method == echo.RouteNotFound {
+			continue
+		}
+		routes = append(routes, RouteInfo{Method: r.Method, Path: r.Path})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// GenDebugInfo is a function that turns a []string of key=value pairs into a map[string]string,
+// with the values of sensitive variables and the matches of the detectors redacted by the Redactor
 func (s *Service) GenDebugInfo(env []string) map[string]string {
+	redactor := s.redactor()
 	info := make(map[string]string)
 	for _, pair := range env {
-		kv := strings.Split(pair, "=")
-		// If the env var ends with 'TOKEN' or 'KEY', then replace the value with 'REDACTED'
-		if strings.HasSuffix(kv[0], "_TOKEN") || strings.HasSuffix(kv[0], "_KEY") {
-			info[kv[0]] = "REDACTED"
+		key, value, _ := strings.Cut(pair, "=")
+		if redactor.Env(key) {
+			info[key] = redactedValue
 			continue
 		}
-		info[kv[0]] = kv[1]
+		info[key] = redactor.String(value)
 	}
 	return info
 }
+
+// redactor returns the Redactor of the service, or the default Redactor if it has none
+func (s *Service) redactor() *Redactor {
+	if s.Redactor == nil {
+		return defaultRedactor
+	}
+	return s.Redactor
+}
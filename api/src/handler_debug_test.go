@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_DebugRoute(t *testing.T) {
+	tests := []struct {
+		name         string
+		environment  string
+		token        string
+		remoteAddr   string
+		header       string
+		forwardedFor string
+		wantStatus   int
+		wantReport   bool
+	}{
+		// the route is not registered, NotFoundHandler responds with status OK
+		{name: "disabled in prod", environment: "prod", remoteAddr: "127.0.0.1:1234", wantStatus: http.StatusOK},
+		{name: "loopback client", environment: "local", remoteAddr: "127.0.0.1:1234", wantStatus: http.StatusOK, wantReport: true},
+		{name: "ipv6 loopback client", environment: "dev", remoteAddr: "[::1]:1234", wantStatus: http.StatusOK, wantReport: true},
+		{name: "remote client without admin token", environment: "dev", remoteAddr: "192.0.2.10:1234", header: "Bearer secret", wantStatus: http.StatusForbidden},
+		{name: "remote client forwarding a loopback address", environment: "dev", remoteAddr: "192.0.2.10:1234", forwardedFor: "127.0.0.1", wantStatus: http.StatusForbidden},
+		{name: "remote client with wrong token", environment: "dev", token: "secret", remoteAddr: "192.0.2.10:1234", header: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "remote client with admin token", environment: "dev", token: "secret", remoteAddr: "192.0.2.10:1234", header: "Bearer secret", wantStatus: http.StatusOK, wantReport: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Environment = tt.environment
+			cfg.Admin.Token = tt.token
+			s, err := NewService(cfg)
+			require.NoError(t, err)
+			e, err := s.BindRoutes()
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			var report map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			if tt.wantReport {
+				assert.Contains(t, report, "routes")
+			} else {
+				assert.NotContains(t, report, "routes")
+			}
+		})
+	}
+}
+
+func TestService_DebugReport(t *testing.T) {
+	t.Setenv("APP_DEBUG_TEST_TOKEN", testToken)
+	cfg := DefaultConfig()
+	cfg.Admin.Token = "admin-secret"
+	cfg.RequestDebug.Secret = "debug-secret"
+	s, err := NewService(cfg)
+	require.NoError(t, err)
+	e, err := s.BindRoutes()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	for _, secret := range []string{"admin-secret", "debug-secret", testToken} {
+		assert.NotContains(t, body, secret, "secret should not be served")
+	}
+
+	var report DebugReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Positive(t, report.Goroutines)
+	assert.Positive(t, report.Memory.Sys)
+	assert.NotEmpty(t, report.Uptime)
+	assert.False(t, report.StartedAt.IsZero())
+	assert.Contains(t, report.Routes, RouteInfo{Method: http.MethodGet, Path: "/debug"})
+	assert.Contains(t, report.Routes, RouteInfo{Method: http.MethodPut, Path: "/loglevel"})
+	assert.Equal(t, redactedValue, report.Environment["APP_DEBUG_TEST_TOKEN"])
+	assert.Equal(t, "local", report.Config["env"])
+	assert.Equal(t, redactedValue, report.Config["admin"].(map[string]any)["token"])
+	if report.Build != nil {
+		assert.NotEmpty(t, report.Build.GoVersion)
+	}
+}
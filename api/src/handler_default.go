@@ -36,10 +36,10 @@ func (s *Service) NotFoundHandler(c echo.Context) error {
 func (s *Service) DefaultInfo(c echo.Context) DefaultInfo {
 	var payload DefaultInfo
 	payload.Title = "Welcome to the default page"
-	payload.Environment = s.GetEnv("MY_ENVTYPE", "local")
-	payload.InstanceType = s.GetEnv("MY_INSTANCETYPE", "local")
-	payload.Service = s.GetEnv("SERVICE_NAME", "default")
-	payload.ServiceVersion = s.GetEnv("SERVICE_VERSION", "v1")
+	payload.Environment = s.Config.Environment
+	payload.InstanceType = s.Config.InstanceType
+	payload.Service = s.Config.ServiceName
+	payload.ServiceVersion = s.Config.ServiceVersion
 	return payload
 }
 
@@ -10,6 +10,6 @@ import (
 // HealthcheckHandler is a function that handles requests to the /healthcheck endpoint.
 func (s *Service) HealthcheckHandler(c echo.Context) error {
 	payload := []string{"OK"}
-	s.AddCustomAttributes(slog.Group(s.Path, s.Any("health", payload)))
+	s.AddCustomAttributes(c, slog.Group(RequestPath(c), s.Any("health", payload)))
 	return c.JSON(http.StatusOK, payload)
 }
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"html"
 	"log/slog"
+	"strings"
 
 	"net/http"
 	"time"
@@ -21,139 +23,57 @@ type APIResponse struct {
 	rawRespBody []byte
 }
 
-// Status represents the response structure for the /status endpoint.
+// Status represents the result of a single check reported by the /status endpoint.
 type Status struct {
-	Name    string
-	Message string
-	Code    int
-	Emoji   string
-	Error   *echo.HTTPError
-	RTT     time.Duration
+	Name    string          `json:"-"`
+	Message string          `json:"message"`
+	Code    int             `json:"code"`
+	Emoji   string          `json:"emoji"`
+	Error   *echo.HTTPError `json:"-"`
+	RTT     time.Duration   `json:"rtt_ms"`
 }
 
-// StatusJSONResponse represents the JSON response structure for the /status endpoint.
-type StatusJSONResponse struct {
-	Service1 string `json:"service1"`
-	Service2 string `json:"service2"`
-	Service3 string `json:"service3"`
-}
+// StatusJSONResponse represents the JSON response structure for the /status endpoint, keyed by check name.
+type StatusJSONResponse map[string]Status
 
 // StatusHandler is a function that handles requests to the /status endpoint.
 func (s *Service) StatusHandler(c echo.Context) error {
-	var payload StatusJSONResponse
-	var service1LogAttr, service2LogAttr, service3LogAttr slog.Attr
-	var numServices int
-
-	if c.QueryParam("debug") != "" {
-		Debug = true
-	}
-
-	numServices = 3
+	statuses := s.Checks.CheckAll(c.Request().Context())
 
-	// Create a channel to receive statuses
-	statusChan := make(chan Status, numServices)
-
-	// Launch each check in a separate goroutine
-	go func() { statusChan <- s.checkService1() }()
-	go func() { statusChan <- s.checkService2() }()
-	go func() { statusChan <- s.checkService3() }()
-
-	// Wait for all checks to complete
-	for i := 0; i < numServices; i++ {
-		status := <-statusChan
-
-		switch status.Name {
-		case "service1":
-			service1LogAttr = s.logStatus(status)
-			payload.Service1 = status.Emoji
-		case "service2":
-			service2LogAttr = s.logStatus(status)
-			payload.Service2 = status.Emoji
-		case "service3":
-			service3LogAttr = s.logStatus(status)
-			payload.Service3 = status.Emoji
+	payload := make(StatusJSONResponse, len(statuses))
+	logAttrs := make([]any, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Error != nil {
+			s.logCustomAttr(c, fmt.Sprintf("%s_CHECK_ERROR", strings.ToUpper(status.Name)), map[string]interface{}{"error": status.Error})
 		}
+		logAttrs = append(logAttrs, s.logStatus(status))
+		payload[status.Name] = status
 	}
 
-	s.AddCustomAttributes(slog.Group(s.Path, service1LogAttr, service2LogAttr, service3LogAttr))
+	s.AddCustomAttributes(c, slog.Group(RequestPath(c), logAttrs...))
 	return c.JSON(http.StatusOK, payload)
 }
 
-// checkStatus1 is a
-func (s *Service) checkService1() Status {
-	var status Status
-
-	startTime := (*s.C).Get("start_time").(time.Time)
-
-	status.Name = "service1"
-
-	response, err := s.mockService(status.Name)
-	if err != nil {
-		s.logCustomAttr("MOCK_SERVICE1_REQUEST_ERROR", map[string]interface{}{"error": err})
-		return s.statusErr(status.Name, startTime, echo.NewHTTPError(http.StatusInternalServerError, err.Error()))
-	}
-
-	status.Code = 200
-	status.Emoji = response.Emoji
-
-	status.Message = response.Message
-	status.RTT = time.Duration(time.Since(startTime).Milliseconds())
-	return status
-}
-
-// checkStatus2 is a function
-func (s *Service) checkService2() Status {
-	var status Status
-
-	startTime := (*s.C).Get("start_time").(time.Time)
-
-	status.Name = "service2"
-
-	response, err := s.mockService(status.Name)
-	if err != nil {
-		s.logCustomAttr("MOCK_SERVICE2_REQUEST_ERROR", map[string]interface{}{"error": err})
-		return s.statusErr(status.Name, startTime, echo.NewHTTPError(http.StatusInternalServerError, err.Error()))
-	}
-
-	status.Code = 200
-	status.Emoji = response.Emoji
-
-	status.Message = response.Message
-	status.RTT = time.Duration(time.Since(startTime).Milliseconds())
-	return status
-}
-
-// checkStatus3 is a function
-func (s *Service) checkService3() Status {
-	var status Status
-
-	startTime := (*s.C).Get("start_time").(time.Time)
-
-	status.Name = "service3"
-
-	response, err := s.mockService(status.Name)
-	if err != nil {
-		s.logCustomAttr("MOCK_SERVICE3_REQUEST_ERROR", map[string]interface{}{"error": err})
-		return s.statusErr(status.Name, startTime, echo.NewHTTPError(http.StatusInternalServerError, err.Error()))
+// statusOK returns a Status for a successful check
+func statusOK(name string, startTime time.Time) Status {
+	return Status{
+		Name:    name,
+		Message: fmt.Sprintf("%s is up", name),
+		Code:    http.StatusOK,
+		Emoji:   html.UnescapeString(emoji.Sprint(":green_circle:")),
+		RTT:     TrackTime(startTime),
 	}
-
-	status.Code = 200
-	status.Emoji = response.Emoji
-
-	status.Message = response.Message
-	status.RTT = time.Duration(time.Since(startTime).Milliseconds())
-	return status
 }
 
 // function that takes an error and returs a Status struct
-func (s *Service) statusErr(name string, startTime time.Time, err *echo.HTTPError) Status {
+func statusErr(name string, startTime time.Time, err *echo.HTTPError) Status {
 	return Status{
 		Name:    name,
-		Message: err.Error(),
+		Message: fmt.Sprint(err.Message),
 		Code:    err.Code,
 		Emoji:   html.UnescapeString(emoji.Sprint(":red_circle:")),
 		Error:   err,
-		RTT:     time.Duration(time.Since(startTime).Milliseconds()),
+		RTT:     TrackTime(startTime),
 	}
 }
 
@@ -164,6 +84,7 @@ func (s *Service) logStatus(status Status) slog.Attr {
 		"code":    status.Code,
 		"message": status.Message,
 		"emoji":   status.Emoji,
+		"rtt":     status.RTT,
 	}
 	if status.Error != nil {
 		logData["error"] = status.Error.Message
@@ -172,15 +93,7 @@ func (s *Service) logStatus(status Status) slog.Attr {
 	return s.genCustomAttr(status.Name, logData)
 }
 
-// mockService is a function to mock a service check
-func (s *Service) mockService(name string) (Status, error) {
-	var status Status
-	startTime := (*s.C).Get("start_time").(time.Time)
-
-	status.Name = name
-	status.Code = http.StatusOK
-	status.Emoji = html.UnescapeString(emoji.Sprint(":green_circle:"))
-	status.Message = fmt.Sprintf("Mock service %s is up", name)
-	status.RTT = time.Duration(time.Since(startTime).Milliseconds())
-	return status, nil
+// mockCheck is a function to mock a dependency check, registered by main for the example services
+func mockCheck(ctx context.Context) error {
+	return nil
 }
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	defaultCheckTimeout = 2 * time.Second
+)
+
+// HealthChecker is the interface for a dependency check reported by the /status endpoint.
+type HealthChecker interface {
+	// Name returns the name the check is reported under.
+	Name() string
+	// Check checks the dependency and returns an error if it is unhealthy.
+	Check(ctx context.Context) error
+}
+
+// CheckOption is a function that configures a registered HealthChecker.
+type CheckOption func(*registeredCheck)
+
+// WithCheckTimeout sets the timeout for a single run of the check, overriding the registry default.
+func WithCheckTimeout(timeout time.Duration) CheckOption {
+	return func(rc *registeredCheck) {
+		rc.timeout = timeout
+	}
+}
+
+// WithRequired marks the check as a required dependency, which makes it part of the /readyz probe.
+func WithRequired() CheckOption {
+	return func(rc *registeredCheck) {
+		rc.required = true
+	}
+}
+
+// registeredCheck is a HealthChecker and the options it was registered with.
+type registeredCheck struct {
+	checker  HealthChecker
+	timeout  time.Duration
+	required bool
+}
+
+// HealthRegistry holds the HealthCheckers registered at startup and runs them on demand.
+type HealthRegistry struct {
+	mu      sync.RWMutex
+	checks  []registeredCheck
+	timeout time.Duration
+}
+
+// NewHealthRegistry creates a new HealthRegistry where each check times out after timeout unless overridden.
+func NewHealthRegistry(timeout time.Duration) *HealthRegistry {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	return &HealthRegistry{
+		timeout: timeout,
+	}
+}
+
+// Register adds checker to the registry. It returns an error if a check with the same name is already registered.
+func (r *HealthRegistry) Register(checker HealthChecker, options ...CheckOption) error {
+	rc := registeredCheck{
+		checker: checker,
+		timeout: r.timeout,
+	}
+	for _, option := range options {
+		option(&rc)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.checks {
+		if existing.checker.Name() == checker.Name() {
+			return fmt.Errorf("health check %q is already registered", checker.Name())
+		}
+	}
+	r.checks = append(r.checks, rc)
+	return nil
+}
+
+// Len returns the number of registered checks.
+func (r *HealthRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.checks)
+}
+
+// checkRequired runs the checks registered with WithRequired concurrently, for the readiness probe.
+// It fails with the names and messages of the failing checks. Their results are logged with log,
+// failures at warn level and successes at debug level.
+func (r *HealthRegistry) checkRequired(ctx context.Context, log *slog.Logger) error {
+	r.mu.RLock()
+	var checks []registeredCheck
+	for _, rc := range r.checks {
+		if rc.required {
+			checks = append(checks, rc)
+		}
+	}
+	r.mu.RUnlock()
+
+	statuses := make([]Status, len(checks))
+	var wg sync.WaitGroup
+	for i, rc := range checks {
+		wg.Add(1)
+		go func(i int, rc registeredCheck) {
+			defer wg.Done()
+			statuses[i] = runCheck(ctx, rc)
+		}(i, rc)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, status := range statuses {
+		if status.Error != nil {
+			log.LogAttrs(ctx, slog.LevelWarn, "CHECK", slog.String("check", status.Name), slog.Duration("rtt", status.RTT), slog.String("error", status.Message))
+			failed = append(failed, status.Name+": "+status.Message)
+			continue
+		}
+		log.LogAttrs(ctx, slog.LevelDebug, "CHECK", slog.String("check", status.Name), slog.Duration("rtt", status.RTT))
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// CheckAll runs all registered checks concurrently and returns their statuses in registration order.
+func (r *HealthRegistry) CheckAll(ctx context.Context) []Status {
+	r.mu.RLock()
+	checks := make([]registeredCheck, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	statuses := make([]Status, len(checks))
+	var wg sync.WaitGroup
+	for i, rc := range checks {
+		wg.Add(1)
+		go func(i int, rc registeredCheck) {
+			defer wg.Done()
+			statuses[i] = runCheck(ctx, rc)
+		}(i, rc)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// runCheck runs a single check, giving up when its timeout expires even if the check does not honor ctx.
+func runCheck(ctx context.Context, rc registeredCheck) Status {
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+	ctx, span := startCheckSpan(ctx, rc.checker.Name())
+	defer span.End()
+
+	startTime := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- rc.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	name := rc.checker.Name()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return statusErr(name, startTime, checkHTTPError(err))
+	}
+	return statusOK(name, startTime)
+}
+
+// checkHTTPError turns the error returned by a check into an *echo.HTTPError.
+func checkHTTPError(err error) *echo.HTTPError {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return echo.NewHTTPError(http.StatusGatewayTimeout, "check timed out").SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error()).SetInternal(err)
+}
+
+// httpCheck is a HealthChecker that makes a GET request to a URL.
+type httpCheck struct {
+	name           string
+	url            string
+	expectedStatus int
+	client         *http.Client
+}
+
+// NewHTTPCheck creates a HealthChecker that makes a GET request to url and expects expectedStatus in the response.
+// If expectedStatus is 0 any 2xx status is accepted. The request is traced as part of the request that ran the check.
+func NewHTTPCheck(name, url string, expectedStatus int) HealthChecker {
+	return &httpCheck{
+		name:           name,
+		url:            url,
+		expectedStatus: expectedStatus,
+		client:         &http.Client{Transport: NewTracingTransport(nil)},
+	}
+}
+
+// Name returns the name of the check.
+func (hc *httpCheck) Name() string {
+	return hc.name
+}
+
+// Check makes the request and compares the response status.
+func (hc *httpCheck) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if hc.expectedStatus != 0 && resp.StatusCode != hc.expectedStatus {
+		return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unexpected status %d, want %d", resp.StatusCode, hc.expectedStatus))
+	}
+	if hc.expectedStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return echo.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// tcpCheck is a HealthChecker that opens a TCP connection to an address.
+type tcpCheck struct {
+	name    string
+	address string
+	dialer  *net.Dialer
+}
+
+// NewTCPCheck creates a HealthChecker that succeeds if a TCP connection to address can be established.
+func NewTCPCheck(name, address string) HealthChecker {
+	return &tcpCheck{
+		name:    name,
+		address: address,
+		dialer:  &net.Dialer{},
+	}
+}
+
+// Name returns the name of the check.
+func (tc *tcpCheck) Name() string {
+	return tc.name
+}
+
+// Check dials the address and closes the connection.
+func (tc *tcpCheck) Check(ctx context.Context) error {
+	conn, err := tc.dialer.DialContext(ctx, "tcp", tc.address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// dnsCheck is a HealthChecker that resolves a host name.
+type dnsCheck struct {
+	name     string
+	host     string
+	resolver *net.Resolver
+}
+
+// NewDNSCheck creates a HealthChecker that succeeds if host resolves to at least one address.
+func NewDNSCheck(name, host string) HealthChecker {
+	return &dnsCheck{
+		name:     name,
+		host:     host,
+		resolver: net.DefaultResolver,
+	}
+}
+
+// Name returns the name of the check.
+func (dc *dnsCheck) Name() string {
+	return dc.name
+}
+
+// Check resolves the host.
+func (dc *dnsCheck) Check(ctx context.Context) error {
+	addrs, err := dc.resolver.LookupHost(ctx, dc.host)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no addresses found for %s", dc.host)
+	}
+	return nil
+}
+
+// funcCheck is a HealthChecker backed by a function.
+type funcCheck struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewFuncCheck creates a HealthChecker that calls fn to check the dependency.
+func NewFuncCheck(name string, fn func(ctx context.Context) error) HealthChecker {
+	return &funcCheck{
+		name: name,
+		fn:   fn,
+	}
+}
+
+// Name returns the name of the check.
+func (fc *funcCheck) Name() string {
+	return fc.name
+}
+
+// Check calls the function.
+func (fc *funcCheck) Check(ctx context.Context) error {
+	return fc.fn(ctx)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthRegistry_Register(t *testing.T) {
+	r := NewHealthRegistry(0)
+	require.NoError(t, r.Register(NewFuncCheck("db", mockCheck)))
+	assert.Error(t, r.Register(NewFuncCheck("db", mockCheck)))
+	assert.Equal(t, 1, r.Len())
+	assert.Equal(t, defaultCheckTimeout, r.timeout)
+}
+
+func TestHealthRegistry_CheckAll(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	r := NewHealthRegistry(time.Second)
+	require.NoError(t, r.Register(NewHTTPCheck("http", upstream.URL, http.StatusOK)))
+	require.NoError(t, r.Register(NewHTTPCheck("http-down", upstream.URL+"/down", 0)))
+	require.NoError(t, r.Register(NewTCPCheck("tcp", ln.Addr().String())))
+	require.NoError(t, r.Register(NewDNSCheck("dns", "localhost")))
+	require.NoError(t, r.Register(NewFuncCheck("func-error", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})))
+	require.NoError(t, r.Register(NewFuncCheck("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}), WithCheckTimeout(10*time.Millisecond)))
+
+	start := time.Now()
+	statuses := r.CheckAll(context.Background())
+	assert.Less(t, time.Since(start), time.Second, "a slow check should not hold up the others")
+
+	got := map[string]int{}
+	for _, status := range statuses {
+		got[status.Name] = status.Code
+	}
+	want := map[string]int{
+		"http":       http.StatusOK,
+		"http-down":  http.StatusBadGateway,
+		"tcp":        http.StatusOK,
+		"dns":        http.StatusOK,
+		"func-error": http.StatusServiceUnavailable,
+		"slow":       http.StatusGatewayTimeout,
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, "http", statuses[0].Name, "statuses should be returned in registration order")
+}
+
+func TestStatusHandler(t *testing.T) {
+	s, e, _ := newTestService(t)
+	require.NoError(t, s.Checks.Register(NewFuncCheck("broken", func(ctx context.Context) error {
+		return errors.New("unreachable")
+	})))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var payload map[string]struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Emoji   string `json:"emoji"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &payload))
+	assert.Len(t, payload, 4)
+	assert.Equal(t, http.StatusOK, payload["service1"].Code)
+	assert.Equal(t, http.StatusServiceUnavailable, payload["broken"].Code)
+	assert.Equal(t, "unreachable", payload["broken"].Message)
+	assert.NotEmpty(t, payload["broken"].Emoji)
+}
+
+func TestProbes_RequiredChecks(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	var down bool
+	require.NoError(t, s.Checks.Register(NewFuncCheck("database", func(ctx context.Context) error {
+		if down {
+			return errors.New("connection refused")
+		}
+		return nil
+	}), WithRequired()))
+	require.NoError(t, s.Checks.Register(NewFuncCheck("optional", func(ctx context.Context) error {
+		return errors.New("not required")
+	})))
+
+	// The checks are registered after newTestService bound the routes, and binding
+	// them again does not add the checks twice
+	e, err := s.BindRoutes()
+	require.NoError(t, err)
+
+	readyz := func() (int, string) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz?verbose", nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, _ := readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code, "readyz should fail before start")
+
+	s.Probes.SetStarted()
+	code, body := readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[+]started ok\n[+]shutdown ok\n[+]checks ok\nreadyz check passed\n", body)
+
+	down = true
+	code, body = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "[-]checks failed: database: connection refused\n")
+
+	down = false
+	s.Probes.SetShuttingDown()
+	code, _ = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code, "readyz should fail while shutting down")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
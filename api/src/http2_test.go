@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+// newH2CClient returns a client that speaks HTTP/2 without TLS with prior knowledge
+func newH2CClient() *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+		Timeout: time.Second,
+	}
+}
+
+func TestService_Run_H2C(t *testing.T) {
+	tests := []struct {
+		name    string
+		h2c     bool
+		wantErr bool
+	}{
+		{name: "h2c enabled", h2c: true},
+		{name: "h2c disabled", h2c: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t)
+			s.Config.HTTP2.H2C = tt.h2c
+			s.Config.HTTP2.MaxConcurrentStreams = 10
+			url, runErr := runTestService(t, s)
+
+			resp, err := newH2CClient().Get(url + "/livez")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, 2, resp.ProtoMajor)
+			}
+
+			resp, err = http.Get(url + "/livez")
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, 1, resp.ProtoMajor, "HTTP/1.1 should still be served")
+
+			s.Stop()
+			require.NoError(t, <-runErr)
+		})
+	}
+}
+
+// mockTransport records the handler it serves and blocks until it is shut down
+type mockTransport struct {
+	mu       sync.Mutex
+	handler  http.Handler
+	served   chan struct{}
+	shutdown chan struct{}
+}
+
+func (m *mockTransport) Serve(handler http.Handler) error {
+	m.mu.Lock()
+	m.handler = handler
+	m.mu.Unlock()
+	close(m.served)
+	<-m.shutdown
+	return http.ErrServerClosed
+}
+
+func (m *mockTransport) Shutdown(ctx context.Context) error {
+	close(m.shutdown)
+	return nil
+}
+
+func TestService_AddTransport(t *testing.T) {
+	s, _, _ := newTestService(t)
+	transport := &mockTransport{served: make(chan struct{}), shutdown: make(chan struct{})}
+	s.AddTransport(transport)
+
+	_, runErr := runTestService(t, s)
+	<-transport.served
+
+	rec := httptest.NewRecorder()
+	transport.mu.Lock()
+	transport.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	transport.mu.Unlock()
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	s.Stop()
+	require.NoError(t, <-runErr)
+	select {
+	case <-transport.shutdown:
+	default:
+		t.Error("transport should be shut down with the service")
+	}
+}
+
+func TestConfig_Validate_HTTP2(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HTTP2.MaxReadFrameSize = 1024
+	assert.ErrorContains(t, cfg.Validate(), "MaxReadFrameSize")
+
+	cfg.HTTP2.MaxReadFrameSize = 1 << 20
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/zate/go-template/api/internal/levels"
+)
+
+// Subsystems of the service with their own logger, whose levels can be overridden with
+// log.subsystems and /loglevel, e.g. checks=debug.
+const (
+	subsystemChecks    = "checks"
+	subsystemRateLimit = "ratelimit"
+	subsystemRestart   = "restart"
+)
+
+// logger returns the logger of the subsystem, that logs with the Logger of the service
+// at the level of the subsystem in Levels.
+func (s *Service) logger(subsystem string) *slog.Logger {
+	return s.Levels.Logger(s.Logger, subsystem)
+}
+
+// newLevels returns the levels.Controller configured by cfg, with the levels of the
+// subsystems given as name=level.
+func newLevels(cfg LogConfig) (*levels.Controller, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+	lc := levels.NewController(level)
+	for _, subsystem := range cfg.Subsystems {
+		name, value, ok := strings.Cut(subsystem, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("log level of subsystem %q: expected name=level", subsystem)
+		}
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return nil, fmt.Errorf("log level of subsystem %s: %w", name, err)
+		}
+		lc.Set(name, level)
+	}
+	return lc, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/go-template/api/internal/ratelimit"
+)
+
+func TestService_LogLevelRoute(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+		wantLevel  slog.Level
+	}{
+		// the route is not registered, NotFoundHandler responds with status OK
+		{name: "disabled without admin token", header: "Bearer secret", wantStatus: http.StatusOK, wantLevel: slog.LevelInfo},
+		{name: "wrong token", token: "secret", header: "Bearer guess", wantStatus: http.StatusUnauthorized, wantLevel: slog.LevelInfo},
+		{name: "admin token", token: "secret", header: "Bearer secret", wantStatus: http.StatusOK, wantLevel: slog.LevelWarn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Admin.Token = tt.token
+			s, err := NewService(cfg)
+			require.NoError(t, err)
+			e, err := s.BindRoutes()
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"warn"}`))
+			req.Header.Set("Authorization", tt.header)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantLevel, s.Levels.Level().Level())
+		})
+	}
+}
+
+func TestNewLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     LogConfig
+		want    map[string]slog.Level
+		wantErr string
+	}{
+		{
+			name: "default and subsystem levels",
+			cfg:  LogConfig{Level: "warn", Subsystems: []string{"checks=debug"}},
+			want: map[string]slog.Level{"": slog.LevelWarn, "checks": slog.LevelDebug, "db": slog.LevelWarn},
+		},
+		{name: "invalid level", cfg: LogConfig{Level: "verbose"}, wantErr: `log level: slog: level string "verbose": unknown name`},
+		{name: "invalid subsystem", cfg: LogConfig{Level: "info", Subsystems: []string{"checks"}}, wantErr: `log level of subsystem "checks": expected name=level`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels, err := newLevels(tt.cfg)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for name, want := range tt.want {
+				got := levels.Level().Level()
+				if name != "" {
+					got = levels.Subsystem(name).Level()
+				}
+				assert.Equal(t, want, got, "level of %q", name)
+			}
+		})
+	}
+}
+
+func TestService_SubsystemLevels(t *testing.T) {
+	readyz := func(fail bool) func(t *testing.T, s *Service) {
+		return func(t *testing.T, s *Service) {
+			require.NoError(t, s.Checks.Register(NewFuncCheck("database", func(ctx context.Context) error {
+				if fail {
+					return errors.New("connection refused")
+				}
+				return nil
+			}), WithRequired()))
+			e, err := s.BindRoutes()
+			require.NoError(t, err)
+			s.Probes.SetStarted()
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		}
+	}
+	rateLimited := func(debug bool) func(t *testing.T, s *Service) {
+		return func(t *testing.T, s *Service) {
+			secret := "debug-secret"
+			s.Config.RequestDebug = RequestDebugConfig{Secret: secret}
+			e := echo.New()
+			e.Use(s.DebugMiddleware())
+			e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, s.RateLimit("root", ratelimit.Policy{Limit: 1, Period: time.Hour}, KeyByIP))
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if debug {
+					req.Header.Set(headerDebug, SignDebugToken(secret, time.Now().Add(time.Minute)))
+				}
+				e.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		subsystem string
+		level     slog.Level
+		override  bool
+		run       func(t *testing.T, s *Service)
+		wantMsg   string
+		wantLevel string
+	}{
+		{name: "passing check at default level", subsystem: subsystemChecks, run: readyz(false)},
+		{name: "passing check with checks=debug", subsystem: subsystemChecks, level: slog.LevelDebug, override: true, run: readyz(false), wantMsg: "CHECK", wantLevel: "DEBUG"},
+		{name: "failing check at default level", subsystem: subsystemChecks, run: readyz(true), wantMsg: "CHECK", wantLevel: "WARN"},
+		{name: "failing check with checks=error", subsystem: subsystemChecks, level: slog.LevelError, override: true, run: readyz(true)},
+		{name: "denied request at default level", subsystem: subsystemRateLimit, run: rateLimited(false)},
+		{name: "denied request with ratelimit=debug", subsystem: subsystemRateLimit, level: slog.LevelDebug, override: true, run: rateLimited(false), wantMsg: "RATE_LIMIT", wantLevel: "DEBUG"},
+		{name: "denied debug request at default level", subsystem: subsystemRateLimit, run: rateLimited(true), wantMsg: "RATE_LIMIT", wantLevel: "DEBUG"},
+		{name: "denied debug request with ratelimit=error", subsystem: subsystemRateLimit, level: slog.LevelError, override: true, run: rateLimited(true), wantMsg: "RATE_LIMIT", wantLevel: "DEBUG"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, buf := newTestService(t)
+			if tt.override {
+				s.Levels.Set(tt.subsystem, tt.level)
+			}
+			tt.run(t, s)
+
+			var got []map[string]any
+			for _, line := range buf.Lines() {
+				if line["subsystem"] == tt.subsystem {
+					got = append(got, line)
+				}
+			}
+			if tt.wantMsg == "" {
+				assert.Empty(t, got, "the %s subsystem should not log", tt.subsystem)
+				return
+			}
+			require.NotEmpty(t, got, "the %s subsystem should log", tt.subsystem)
+			assert.Equal(t, tt.wantMsg, got[0]["msg"])
+			assert.Equal(t, tt.wantLevel, got[0]["level"])
+		})
+	}
+}
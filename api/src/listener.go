@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net"
+
+	"github.com/zate/go-template/api/internal/listener"
+)
+
+// listen returns the listener for the TCP address handed off by the previous process on
+// restart, or listens on address if there is none. It also returns the name of the listener
+// to hand it off on the next restart.
+func listen(address string) (net.Listener, string, error) {
+	c := listener.Config{Network: listener.NetworkTCP, Address: address}
+	lns, err := listener.Listen(c)
+	if err != nil {
+		return nil, "", err
+	}
+	return lns[0], c.String(), nil
+}
+
+// closeListeners closes listeners, e.g. when Run returns before serving them.
+func closeListeners(listeners []net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"github.com/zate/go-template/api/internal/levels"
+	"github.com/zate/go-template/api/internal/logctx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the attributes added to records from their context, the same as those of
+// the request log.
+const (
+	logKeyRequestID = "id"
+	logKeyTraceID   = "trace-id"
+	logKeySpanID    = "span-id"
+	logKeyClientIP  = "client-ip"
+)
+
+// logKeys are the keys of the values of the context logged by the logctx.Handler of
+// the service.
+var logKeys = logctx.Keys{RequestID: logKeyRequestID, TraceID: logKeyTraceID, SpanID: logKeySpanID}
+
+// newContextHandler returns a handler that adds the values of the context of a record
+// to it before passing it to handler: the request ID, the IDs of the current span and
+// the attributes stored in the context, so that records logged with the context of a
+// request can be correlated with its request log and trace. Records logged with the
+// context of a debug request are handled at every level, see debugHandler.
+func newContextHandler(handler slog.Handler, extractors ...logctx.Extractor) slog.Handler {
+	return &debugHandler{handler: logctx.NewHandler(handler, logKeys, append([]logctx.Extractor{spanAttrs}, extractors...)...)}
+}
+
+// spanAttrs returns the trace and span IDs of the span of ctx, if it has a valid one.
+func spanAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return []slog.Attr{slog.String(logKeyTraceID, sc.TraceID().String()), slog.String(logKeySpanID, sc.SpanID().String())}
+	}
+	return nil
+}
+
+// debugHandler is a slog.Handler that handles the records logged with the context of a
+// debug request at every level, with the debug attribute and the filename and line of
+// their caller.
+type debugHandler struct {
+	handler slog.Handler
+}
+
+// Enabled reports whether the handler it wraps handles records at level, or ctx is
+// the context of a debug request.
+func (h *debugHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return levels.Debug(ctx) || h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the debug attributes to r if ctx is the context of a debug request, and
+// passes it to the handler it wraps.
+func (h *debugHandler) Handle(ctx context.Context, r slog.Record) error {
+	if levels.Debug(ctx) {
+		r = r.Clone()
+		r.AddAttrs(debugAttrs(r.PC)...)
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a debugHandler wrapping the handler with attrs.
+func (h *debugHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &debugHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a debugHandler wrapping the handler with the group name.
+func (h *debugHandler) WithGroup(name string) slog.Handler {
+	return &debugHandler{handler: h.handler.WithGroup(name)}
+}
+
+// debugAttrs returns the debug attribute and the filename and line of the caller that
+// logged the record with the program counter pc.
+func debugAttrs(pc uintptr) []slog.Attr {
+	attrs := []slog.Attr{slog.Bool("debug", true)}
+	if pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		attrs = append(attrs, slog.String("filename", frame.File), slog.Int("line", frame.Line))
+	}
+	return attrs
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/go-template/api/internal/logctx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewContextHandler(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	tenant := func(ctx context.Context) []slog.Attr {
+		if logctx.RequestID(ctx) == "" {
+			return nil
+		}
+		return []slog.Attr{slog.String("tenant", "acme")}
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		args []any
+		want map[string]any
+	}{
+		{
+			name: "no context values",
+			ctx:  context.Background(),
+			want: map[string]any{},
+		},
+		{
+			name: "request ID, span and attributes",
+			ctx:  logctx.WithAttrs(logctx.WithRequestID(trace.ContextWithSpanContext(context.Background(), sc), "abc-123"), slog.String("job", "cleanup")),
+			want: map[string]any{"id": "abc-123", "trace-id": sc.TraceID().String(), "span-id": sc.SpanID().String(), "job": "cleanup", "tenant": "acme"},
+		},
+		{
+			name: "attributes of the record take precedence",
+			ctx:  logctx.WithRequestID(context.Background(), "abc-123"),
+			args: []any{"id", "def-456"},
+			want: map[string]any{"id": "def-456", "tenant": "acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(newContextHandler(slog.NewJSONHandler(&buf, nil), tenant))
+			logger.InfoContext(tt.ctx, "message", tt.args...)
+
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+			for _, key := range []string{"time", "level", "msg"} {
+				delete(line, key)
+			}
+			assert.Equal(t, tt.want, line)
+		})
+	}
+}
+
+func TestService_LogCorrelation(t *testing.T) {
+	s, e, buf := newTestService(t)
+	e.GET("/work", func(c echo.Context) error {
+		s.Log(c.Request().Context(), "info", "WORK")
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/work", nil)
+	req.Header.Set(echo.HeaderXRequestID, "abc-123")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := map[string]map[string]any{}
+	for _, line := range buf.Lines() {
+		lines[line["msg"].(string)] = line
+	}
+	require.Contains(t, lines, "WORK")
+	require.Contains(t, lines, "REQUEST")
+	for _, key := range []string{"id", "trace-id", "span-id"} {
+		assert.NotEmpty(t, lines["WORK"][key], "%s should be logged", key)
+		assert.Equal(t, lines["REQUEST"][key], lines["WORK"][key], "%s should match the request log", key)
+	}
+	assert.Equal(t, "abc-123", lines["WORK"]["id"])
+}
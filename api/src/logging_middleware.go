@@ -13,6 +13,8 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/samber/lo"
+	"github.com/zate/go-template/api/internal/levels"
+	"github.com/zate/go-template/api/internal/metrics"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -23,18 +25,6 @@ const (
 var (
 	RequestBodyMaxSize  = 64 * 1024 // 64KB
 	ResponseBodyMaxSize = 64 * 1024 // 64KB
-
-	HiddenRequestHeaders = map[string]struct{}{
-		"authorization": {},
-		"cookie":        {},
-		"set-cookie":    {},
-		"x-auth-token":  {},
-		"x-csrf-token":  {},
-		"x-xsrf-token":  {},
-	}
-	HiddenResponseHeaders = map[string]struct{}{
-		"set-cookie": {},
-	}
 )
 
 type LoggingConfig struct {
@@ -54,6 +44,14 @@ type LoggingConfig struct {
 	Message string
 
 	Filters []Filter
+
+	// Metrics records the status, latency, route and lengths of requests, including
+	// those excluded from the logs by Filters. Disabled if nil.
+	Metrics *metrics.Metrics
+
+	// Redactor redacts the headers, query and bodies of requests and responses.
+	// Defaults to a Redactor with DefaultRedactionConfig.
+	Redactor *Redactor
 }
 
 // New returns a echo.MiddlewareFunc (middleware) that logs requests using slog.
@@ -108,13 +106,18 @@ func NewLoggingMiddlewareWithFilters(logger *slog.Logger, filters ...Filter) ech
 
 // NewWithConfig returns a echo.HandlerFunc (middleware) that logs requests using slog.
 func NewLoggingMiddlewareWithConfig(logger *slog.Logger, config LoggingConfig) echo.MiddlewareFunc {
+	redactor := config.Redactor
+	if redactor == nil {
+		redactor = defaultRedactor
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) (err error) {
 			req := c.Request()
 			res := c.Response()
 			start := time.Now()
 			path := req.URL.Path
-			query := req.URL.RawQuery
+			query := redactor.Query(req.URL.RawQuery)
 
 			params := map[string]string{}
 			for i, k := range c.ParamNames() {
@@ -129,6 +132,10 @@ func NewLoggingMiddlewareWithConfig(logger *slog.Logger, config LoggingConfig) e
 			bw := newBodyWriter(res.Writer, ResponseBodyMaxSize, config.WithResponseBody)
 			res.Writer = bw
 
+			if config.Metrics != nil {
+				defer config.Metrics.Start()()
+			}
+
 			err = next(c)
 
 			if err != nil {
@@ -153,6 +160,10 @@ func NewLoggingMiddlewareWithConfig(logger *slog.Logger, config LoggingConfig) e
 				}
 			}
 
+			if config.Metrics != nil {
+				config.Metrics.Observe(route, method, status, latency, br.bytes, bw.bytes)
+			}
+
 			baseAttributes := []slog.Attr{}
 
 			requestAttributes := []slog.Attr{
@@ -196,16 +207,14 @@ func NewLoggingMiddlewareWithConfig(logger *slog.Logger, config LoggingConfig) e
 			// request body
 			requestAttributes = append(requestAttributes, slog.Int("length", br.bytes))
 			if config.WithRequestBody {
-				requestAttributes = append(requestAttributes, slog.String("body", br.body.String()))
+				requestAttributes = append(requestAttributes, slog.String("body", redactor.Body(br.body.String(), req.Header.Get(echo.HeaderContentType))))
 			}
 
-			// request headers
-			if config.WithRequestHeader {
+			// request headers, and response headers below, are logged for debug requests
+			debug := levels.Debug(c.Request().Context())
+			if config.WithRequestHeader || debug {
 				for k, v := range c.Request().Header {
-					if _, found := HiddenRequestHeaders[strings.ToLower(k)]; found {
-						continue
-					}
-					requestAttributes = append(requestAttributes, slog.Group("header", slog.Any(k, v)))
+					requestAttributes = append(requestAttributes, slog.Group("header", slog.Any(k, redactor.Header(k, v))))
 				}
 			}
 
@@ -224,16 +233,13 @@ func NewLoggingMiddlewareWithConfig(logger *slog.Logger, config LoggingConfig) e
 			// response body body
 			responseAttributes = append(responseAttributes, slog.Int("length", bw.bytes))
 			if config.WithResponseBody {
-				responseAttributes = append(responseAttributes, slog.String("body", bw.body.String()))
+				responseAttributes = append(responseAttributes, slog.String("body", redactor.Body(bw.body.String(), res.Header().Get(echo.HeaderContentType))))
 			}
 
 			// response headers
-			if config.WithResponseHeader {
+			if config.WithResponseHeader || debug {
 				for k, v := range c.Response().Header() {
-					if _, found := HiddenResponseHeaders[strings.ToLower(k)]; found {
-						continue
-					}
-					responseAttributes = append(responseAttributes, slog.Group("header", slog.Any(k, v)))
+					responseAttributes = append(responseAttributes, slog.Group("header", slog.Any(k, redactor.Header(k, v))))
 				}
 			}
 
@@ -647,7 +653,7 @@ type bodyWriter struct {
 }
 
 // implements gin.ResponseWriter
-func (w bodyWriter) Write(b []byte) (int, error) {
+func (w *bodyWriter) Write(b []byte) (int, error) {
 	if w.body != nil {
 		if w.body.Len()+len(b) > w.maxSize {
 			w.body.Write(b[:w.maxSize-w.body.Len()])
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"log/slog"
+	"os"
 )
 
 //	@title			AIchemist AI Toolkit
@@ -14,44 +16,49 @@ import (
 //	@BasePath	/
 
 var (
-	Environment string
-	AWS_Region  string
-	ServiceName string
-	XHeaders    map[string]string
-	URL         string
-	Debug       bool = false
-	S           *Service
+	XHeaders map[string]string
+	URL      string
+	S        *Service
 )
 
 func init() {
-	Environment = getEnv("APP_ENV", "local")
-	AWS_Region = getEnv("AWS_REGION", "local")
-	ServiceName = getEnv("SERVICE_NAME", "no_service_name_set")
-
 	XHeaders = map[string]string{
 		"content-type": "application/json",
 	}
 }
 
 func main() {
-	listenPort := flag.Int("port", 8080, "port to listen on")
-	flag.Parse()
-	S, err := NewService(*listenPort)
+	cfg, err := LoadConfig(defaultConfigPrefix, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
+	S, err := NewService(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	// Register the dependencies reported by /status here.
+	for _, name := range []string{"service1", "service2", "service3"} {
+		if err := S.Checks.Register(NewFuncCheck(name, mockCheck)); err != nil {
+			panic(err)
+		}
+	}
 
 	S.Logger = S.Logger.With(slog.Group(
 		"service",
-		slog.Any("name", ServiceName),
-		slog.Any("environment", Environment),
-		slog.Any("region", AWS_Region),
-		slog.Any("port", *listenPort),
+		slog.Any("name", cfg.ServiceName),
+		slog.Any("environment", cfg.Environment),
+		slog.Any("region", cfg.Region),
+		slog.Any("port", cfg.Port),
 	))
 	S.Logger.LogAttrs(
 		context.Background(),
 		slog.LevelInfo,
 		"STARTING_SERVICE",
+		slog.Any("config", cfg),
 	)
 
 	err = S.Run()
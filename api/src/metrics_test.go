@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrapeMetrics returns the lines served on /metrics at url that start with prefix.
+func scrapeMetrics(t *testing.T, url, prefix string) []string {
+	t.Helper()
+	resp, err := http.Get(url + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), prefix) {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	return lines
+}
+
+func TestService_Metrics(t *testing.T) {
+	_, e, _ := newTestService(t)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	for _, path := range []string{"/livez", "/livez", "/healthcheck", "/unknown/1", "/unknown/2"} {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	requests := scrapeMetrics(t, srv.URL, "http_requests_total{")
+	assert.Contains(t, requests, `http_requests_total{method="GET",route="/livez",status="200"} 2`)
+	assert.Contains(t, requests, `http_requests_total{method="GET",route="/healthcheck",status="200"} 1`)
+	for _, line := range requests {
+		assert.NotContains(t, line, "/unknown/", "paths without a route should not create series")
+	}
+
+	sizes := scrapeMetrics(t, srv.URL, `http_response_size_bytes_sum{method="GET",route="/healthcheck"`)
+	require.Len(t, sizes, 1)
+	assert.NotEqual(t, "0", sizes[0][strings.LastIndex(sizes[0], " ")+1:], "response size should be recorded")
+
+	assert.NotEmpty(t, scrapeMetrics(t, srv.URL, "go_goroutines"), "Go runtime metrics should be collected")
+	assert.Equal(t, []string{"http_requests_in_flight 1"}, scrapeMetrics(t, srv.URL, "http_requests_in_flight"))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/zate/go-template/api/internal/ratelimit"
+	"golang.org/x/exp/slices"
+)
+
+// Keys of RateLimitConfig.Key.
+const (
+	rateLimitKeyIP     = "ip"
+	rateLimitKeyAPIKey = "api_key"
+	rateLimitKeyRoute  = "route"
+)
+
+// rateLimitExempt are the routes that are never rate limited, so that probes and scrapes keep working.
+var rateLimitExempt = []string{"/livez", "/readyz", "/startupz", "/healthcheck", "/metrics"}
+
+// RateLimitKey returns the key a request is rate limited by. Requests with the same key
+// share a bucket.
+type RateLimitKey func(c echo.Context) string
+
+// KeyByIP rate limits requests by the client IP, see echo.Context.RealIP and
+// Config.TrustedProxies.
+func KeyByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// KeyByAPIKey returns a RateLimitKey of the API key in the header name, e.g. X-API-Key.
+// Requests without an API key are limited by the client IP.
+func KeyByAPIKey(name string) RateLimitKey {
+	return func(c echo.Context) string {
+		if key := c.Request().Header.Get(name); key != "" {
+			return "key:" + ratelimit.HashKey(key)
+		}
+		return KeyByIP(c)
+	}
+}
+
+// KeyByRoute rate limits requests by their method and route, e.g. GET /users/:id, so
+// that all clients share the limit of a route.
+func KeyByRoute(c echo.Context) string {
+	return "route:" + c.Request().Method + " " + c.Path()
+}
+
+// rateLimitGroup is the rate limit policy of the routes with a prefix.
+type rateLimitGroup struct {
+	prefix string
+	policy ratelimit.Policy
+}
+
+// matches reports whether the route path is in the group.
+func (g rateLimitGroup) matches(path string) bool {
+	return g.prefix == "/" || path == g.prefix || strings.HasPrefix(path, g.prefix+"/")
+}
+
+// newRateLimitGroups returns the groups of cfg given as prefix=limit/period, ordered
+// from the longest prefix.
+func newRateLimitGroups(cfg RateLimitConfig) ([]rateLimitGroup, error) {
+	groups := make([]rateLimitGroup, 0, len(cfg.Groups))
+	for _, group := range cfg.Groups {
+		prefix, value, ok := strings.Cut(group, "=")
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("rate limit of group %q: expected prefix=limit/period", group)
+		}
+		policy, err := ratelimit.ParsePolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit of group %s: %w", prefix, err)
+		}
+		if prefix != "/" {
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+		groups = append(groups, rateLimitGroup{prefix: prefix, policy: policy})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].prefix) > len(groups[j].prefix)
+	})
+	return groups, nil
+}
+
+// rateLimitKey returns the RateLimitKey configured by cfg.
+func rateLimitKey(cfg RateLimitConfig) RateLimitKey {
+	switch cfg.Key {
+	case rateLimitKeyAPIKey:
+		return KeyByAPIKey(cfg.APIKeyHeader)
+	case rateLimitKeyRoute:
+		return KeyByRoute
+	}
+	return KeyByIP
+}
+
+// RateLimitMiddleware returns a middleware that limits the requests of the route groups
+// configured by rate_limit.groups, with the policy of the longest prefix of their route.
+// Probes and metrics are never limited.
+func (s *Service) RateLimitMiddleware() echo.MiddlewareFunc {
+	key := rateLimitKey(s.Config.RateLimit)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Path()
+			if slices.Contains(rateLimitExempt, path) {
+				return next(c)
+			}
+			for _, group := range s.rateLimits {
+				if group.matches(path) {
+					return s.rateLimit(c, next, group.prefix, group.policy, key)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimit returns a middleware that limits requests with policy by key, e.g. for an
+// echo.Group. name separates its buckets from those of other policies.
+func (s *Service) RateLimit(name string, policy ratelimit.Policy, key RateLimitKey) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return s.rateLimit(c, next, name, policy, key)
+		}
+	}
+}
+
+// rateLimit takes a token for the request in c from the bucket of name and key. It sets
+// the RateLimit-* headers, and responds with ErrTooManyRequests if the request is denied.
+// If the store fails, the error is logged and the request is allowed. Denied requests
+// are logged at debug level by the ratelimit subsystem.
+func (s *Service) rateLimit(c echo.Context, next echo.HandlerFunc, name string, policy ratelimit.Policy, key RateLimitKey) error {
+	ctx := c.Request().Context()
+	log := s.logger(subsystemRateLimit)
+	res, err := s.RateLimiter.Take(ctx, name+"|"+key(c), policy)
+	if err != nil {
+		log.LogAttrs(ctx, slog.LevelError, "RATE_LIMIT", slog.String("error", err.Error()))
+	}
+	res.WriteHeaders(c.Response().Header())
+	if !res.Allowed {
+		log.LogAttrs(ctx, slog.LevelDebug, "RATE_LIMIT", slog.String("group", name), slog.Bool("allowed", false), slog.Duration("retry-after", res.RetryAfter))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded").SetInternal(ErrTooManyRequests)
+	}
+	return next(c)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/go-template/api/internal/ratelimit"
+)
+
+// failingRedis is a ratelimit.RedisEvaler that fails with err.
+type failingRedis struct {
+	err error
+}
+
+func (f failingRedis) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	return nil, f.err
+}
+
+func TestService_RateLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      RateLimitConfig
+		requests []string // path and client IP separated by a space
+		want     []int
+	}{
+		{
+			name:     "disabled without groups",
+			cfg:      RateLimitConfig{Key: rateLimitKeyIP},
+			requests: []string{"/users/1 192.0.2.1", "/users/1 192.0.2.1"},
+			want:     []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:     "by ip",
+			cfg:      RateLimitConfig{Groups: []string{"/=1/1h"}, Key: rateLimitKeyIP},
+			requests: []string{"/users/1 192.0.2.1", "/items 192.0.2.1", "/users/1 192.0.2.2"},
+			want:     []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name:     "longest prefix",
+			cfg:      RateLimitConfig{Groups: []string{"/=1/1h", "/users=2/1h"}, Key: rateLimitKeyIP},
+			requests: []string{"/users/1 192.0.2.1", "/users/2 192.0.2.1", "/users/3 192.0.2.1", "/items 192.0.2.1", "/items 192.0.2.1"},
+			want:     []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "by route",
+			cfg:      RateLimitConfig{Groups: []string{"/users=1/1h"}, Key: rateLimitKeyRoute},
+			requests: []string{"/users/1 192.0.2.1", "/users/2 192.0.2.2", "/items 192.0.2.1"},
+			want:     []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name:     "probes are exempt",
+			cfg:      RateLimitConfig{Groups: []string{"/=1/1h"}, Key: rateLimitKeyIP},
+			requests: []string{"/livez 192.0.2.1", "/livez 192.0.2.1", "/metrics 192.0.2.1"},
+			want:     []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.RateLimit.Groups = tt.cfg.Groups
+			cfg.RateLimit.Key = tt.cfg.Key
+			s, err := NewService(cfg)
+			require.NoError(t, err)
+			e, err := s.BindRoutes()
+			require.NoError(t, err)
+			e.GET("/users/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+			e.GET("/items", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+			var got []int
+			for _, r := range tt.requests {
+				path, ip, _ := strings.Cut(r, " ")
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.RemoteAddr = ip + ":1234"
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				got = append(got, rec.Code)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_RateLimit(t *testing.T) {
+	s, _, buf := newTestService(t)
+	s.RateLimiter = ratelimit.NewLimiter(nil)
+	e, err := s.BindRoutes()
+	require.NoError(t, err)
+	api := e.Group("/api", s.RateLimit("api", ratelimit.Policy{Limit: 1, Period: time.Minute}, KeyByAPIKey("X-API-Key")))
+	api.GET("/items", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	request := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("one")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1;w=60", rec.Header().Get("RateLimit-Policy"))
+
+	rec = request("one")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, ErrTooManyRequests.Error(), problem.Code)
+	assert.Equal(t, "rate limit exceeded", problem.Detail)
+
+	assert.Equal(t, http.StatusOK, request("two").Code)
+	assert.NotContains(t, buf.String(), "RATE_LIMIT")
+}
+
+func TestService_RateLimit_StoreError(t *testing.T) {
+	s, _, buf := newTestService(t)
+	s.RateLimiter = ratelimit.NewLimiter(ratelimit.NewRedisStore(failingRedis{err: errors.New("connection refused")}, ""))
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, s.RateLimit("root", ratelimit.Policy{Limit: 1, Period: time.Hour}, KeyByIP))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code, "requests should be allowed when the store fails")
+	}
+	assert.Contains(t, buf.String(), `"msg":"RATE_LIMIT","subsystem":"ratelimit","error":"rate limit: connection refused"`)
+}
+
+func TestNewRateLimitGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  []string
+		want    []rateLimitGroup
+		wantErr string
+	}{
+		{
+			name:   "ordered by prefix length",
+			groups: []string{"/=100/1m", "/users/=10/s"},
+			want: []rateLimitGroup{
+				{prefix: "/users", policy: ratelimit.Policy{Limit: 10, Period: time.Second}},
+				{prefix: "/", policy: ratelimit.Policy{Limit: 100, Period: time.Minute}},
+			},
+		},
+		{name: "without policy", groups: []string{"/users"}, wantErr: `rate limit of group "/users": expected prefix=limit/period`},
+		{name: "invalid policy", groups: []string{"/users=10"}, wantErr: `rate limit of group /users: rate limit "10": expected limit/period`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRateLimitGroups(RateLimitConfig{Groups: tt.groups})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces the values removed by a Redactor.
+const redactedValue = "REDACTED"
+
+// Built-in detectors of RedactionConfig.Detectors.
+const (
+	detectorEmail  = "email"
+	detectorCard   = "card"
+	detectorBearer = "bearer"
+	detectorAWSKey = "aws_key"
+)
+
+// detectorPatterns are the patterns of the built-in detectors.
+var detectorPatterns = map[string]*regexp.Regexp{
+	detectorEmail:  regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	detectorCard:   regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	detectorBearer: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	detectorAWSKey: regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[A-Z0-9]{16}\b`),
+}
+
+// defaultRedactor redacts with DefaultRedactionConfig, e.g. for a LoggingConfig without a Redactor.
+var defaultRedactor = func() *Redactor {
+	r, err := NewRedactor(DefaultRedactionConfig())
+	if err != nil {
+		panic(err)
+	}
+	return r
+}()
+
+// DefaultRedactionConfig returns the RedactionConfig of the credentials commonly sent to
+// and logged by services, with every built-in detector.
+func DefaultRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Headers:     []string{"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key", "x-auth-token", "x-csrf-token", "x-xsrf-token", "x-debug"},
+		Fields:      []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "id_token", "client_secret", "api_key", "apikey", "private_key"},
+		QueryParams: []string{"token", "access_token", "api_key", "apikey", "key", "signature", "password"},
+		Env:         []string{"*_TOKEN", "*_KEY", "*_SECRET", "*PASSWORD*", "*CREDENTIALS*"},
+		Detectors:   []string{detectorEmail, detectorCard, detectorBearer, detectorAWSKey},
+	}
+}
+
+// Redactor removes sensitive data from the values that are logged or served for
+// debugging: headers and query parameters by name, JSON fields by path, environment
+// variables by pattern, and any string matching a detector, e.g. an email address.
+type Redactor struct {
+	headers   map[string]bool
+	params    map[string]bool
+	keys      map[string]bool
+	paths     [][]string
+	env       []string
+	detectors []*regexp.Regexp
+	// fieldPattern matches the fields of keys in text that is not valid JSON, e.g. a truncated body.
+	fieldPattern *regexp.Regexp
+}
+
+// NewRedactor returns a Redactor configured by cfg. Field paths are separated with a dot
+// and matched from the root of a JSON document, "*" matches any key and the elements of
+// an array have the path of the array, e.g. "user.ssn", "items.cvv" or "accounts.*.pin".
+// A field without a dot matches the key at any depth, and the key of any log attribute.
+// Env patterns use path.Match, e.g. "*_TOKEN".
+func NewRedactor(cfg RedactionConfig) (*Redactor, error) {
+	r := &Redactor{
+		headers: lowerSet(cfg.Headers),
+		params:  lowerSet(cfg.QueryParams),
+		keys:    map[string]bool{},
+	}
+	var keys []string
+	for _, field := range cfg.Fields {
+		segments := strings.Split(strings.ToLower(field), ".")
+		if len(segments) == 1 {
+			r.keys[segments[0]] = true
+			keys = append(keys, regexp.QuoteMeta(segments[0]))
+			continue
+		}
+		r.paths = append(r.paths, segments)
+	}
+	if len(keys) > 0 {
+		r.fieldPattern = regexp.MustCompile(`(?i)("(?:` + strings.Join(keys, "|") + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+	for _, pattern := range cfg.Env {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("redaction: env pattern %q: %w", pattern, err)
+		}
+		r.env = append(r.env, strings.ToUpper(pattern))
+	}
+	for _, name := range cfg.Detectors {
+		re, ok := detectorPatterns[name]
+		if !ok {
+			return nil, fmt.Errorf("redaction: unknown detector %q", name)
+		}
+		r.detectors = append(r.detectors, re)
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction: pattern %q: %w", pattern, err)
+		}
+		r.detectors = append(r.detectors, re)
+	}
+	return r, nil
+}
+
+// lowerSet returns the set of values in lower case.
+func lowerSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToLower(v)] = true
+	}
+	return set
+}
+
+// String returns s with the matches of the detectors replaced.
+func (r *Redactor) String(s string) string {
+	for _, re := range r.detectors {
+		if re == detectorPatterns[detectorCard] {
+			s = re.ReplaceAllStringFunc(s, func(match string) string {
+				if luhn(match) {
+					return redactedValue
+				}
+				return match
+			})
+			continue
+		}
+		s = re.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of card numbers.
+func luhn(s string) bool {
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// Header returns the values of the header name, redacted if the header is sensitive.
+func (r *Redactor) Header(name string, values []string) []string {
+	redacted := make([]string, len(values))
+	for i, v := range values {
+		if r.headers[strings.ToLower(name)] {
+			redacted[i] = redactedValue
+			continue
+		}
+		redacted[i] = r.String(v)
+	}
+	return redacted
+}
+
+// Query returns the URL encoded query raw with the values of sensitive parameters redacted.
+func (r *Redactor) Query(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return r.String(raw)
+	}
+	for name, vs := range values {
+		for i, v := range vs {
+			if r.params[strings.ToLower(name)] {
+				vs[i] = redactedValue
+				continue
+			}
+			vs[i] = r.String(v)
+		}
+	}
+	return values.Encode()
+}
+
+// Body returns the captured body of the content type with sensitive data redacted: the
+// fields of a JSON body, the parameters of a form, and the matches of the detectors.
+func (r *Redactor) Body(body, contentType string) string {
+	if body == "" {
+		return body
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		return r.Query(body)
+	}
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if redacted, ok := r.json(trimmed); ok {
+			return redacted
+		}
+		// e.g. a body truncated to the maximum size
+		if r.fieldPattern != nil {
+			body = r.fieldPattern.ReplaceAllString(body, `$1"`+redactedValue+`"`)
+		}
+	}
+	return r.String(body)
+}
+
+// json returns the JSON document s with sensitive fields redacted, and whether s is valid JSON.
+func (r *Redactor) json(s string) (string, bool) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return "", false
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.value(v, nil)); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// value returns the JSON value v at path with sensitive fields redacted.
+func (r *Redactor) value(v any, path []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			p := append(path[:len(path):len(path)], strings.ToLower(k))
+			if r.field(p) {
+				v[k] = redactedValue
+				continue
+			}
+			v[k] = r.value(child, p)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.value(child, path)
+		}
+	case string:
+		return r.String(v)
+	}
+	return v
+}
+
+// field reports whether the field at the lower case path is sensitive.
+func (r *Redactor) field(path []string) bool {
+	if r.keys[path[len(path)-1]] {
+		return true
+	}
+	for _, p := range r.paths {
+		if len(p) != len(path) {
+			continue
+		}
+		match := true
+		for i := range p {
+			if p[i] != "*" && p[i] != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Env reports whether the value of the environment variable name is sensitive.
+func (r *Redactor) Env(name string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range r.env {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Attr returns a with sensitive data redacted: the values of sensitive keys, strings and
+// JSON documents in strings, and the JSON encoding of maps, structs and slices, recursively in groups.
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if r.keys[strings.ToLower(a.Key)] && !(a.Value.Kind() == slog.KindString && a.Value.String() == "") {
+		return slog.String(a.Key, redactedValue)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.Body(a.Value.String(), ""))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = r.Attr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.String(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.String(v.String()))
+		}
+		return r.any(a)
+	}
+	return a
+}
+
+// any returns the attribute a of kind slog.KindAny with sensitive data redacted. Only the
+// values that hold fields or strings, e.g. a struct or a map, are walked through their
+// JSON encoding, and only if the Redactor has rules that may match them.
+func (r *Redactor) any(a slog.Attr) slog.Attr {
+	v := reflect.ValueOf(a.Value.Any())
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return a
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return slog.String(a.Key, r.Body(v.String(), ""))
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+		if len(r.keys) == 0 && len(r.paths) == 0 && len(r.detectors) == 0 {
+			return a
+		}
+	default:
+		// e.g. a number of a named type, which holds no sensitive data
+		return a
+	}
+	b, err := json.Marshal(a.Value.Any())
+	if err != nil {
+		return slog.String(a.Key, r.String(fmt.Sprint(a.Value.Any())))
+	}
+	return slog.Any(a.Key, json.RawMessage(r.Body(string(b), "application/json")))
+}
+
+// redactHandler is a slog.Handler that redacts the message and attributes of records
+// with a Redactor before passing them to the handler it wraps.
+type redactHandler struct {
+	handler  slog.Handler
+	redactor *Redactor
+}
+
+// newRedactHandler returns a redactHandler that passes records redacted by redactor to handler.
+func newRedactHandler(handler slog.Handler, redactor *Redactor) *redactHandler {
+	return &redactHandler{handler: handler, redactor: redactor}
+}
+
+// Enabled reports whether the handler it wraps handles records at level.
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle redacts r and passes it to the handler it wraps.
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, h.redactor.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.Attr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a redactHandler wrapping the handler with attrs redacted.
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactor.Attr(a)
+	}
+	return &redactHandler{handler: h.handler.WithAttrs(redacted), redactor: h.redactor}
+}
+
+// WithGroup returns a redactHandler wrapping the handler with the group name.
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{handler: h.handler.WithGroup(name), redactor: h.redactor}
+}
@@ -0,0 +1,149 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envRestartHelper holds the port TestRestartHelper listens on.
+const envRestartHelper = "API_RESTART_HELPER"
+
+// TestRestartHelper is not a test, it runs the service for TestService_Restart in a
+// separate process, and again in the process started by the restart.
+func TestRestartHelper(t *testing.T) {
+	port := os.Getenv(envRestartHelper)
+	if port == "" {
+		t.Skip("helper process for TestService_Restart")
+	}
+
+	s, err := NewService(DefaultConfig())
+	if err != nil {
+		os.Exit(1)
+	}
+	s.Logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s.Port, _ = strconv.Atoi(port)
+	s.Checks.Register(NewFuncCheck("slow", func(ctx context.Context) error {
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	}), WithCheckTimeout(time.Second))
+	if err := s.Run(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestService_Restart(t *testing.T) {
+	port := freePort(t)
+	url := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	// The new process inherits stdout, so the pipe is read directly rather than
+	// through exec, which would wait for the new process to exit.
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	buf := &syncBuffer{}
+	go io.Copy(buf, r)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRestartHelper$")
+	cmd.Env = append(os.Environ(), envRestartHelper+"="+strconv.Itoa(port))
+	cmd.Stdout = w
+	require.NoError(t, cmd.Start())
+	w.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	get := func(path string) (int, error) {
+		resp, err := client.Get(url + path)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	if !assert.Eventually(t, func() bool {
+		code, err := get("/livez")
+		return err == nil && code == http.StatusOK
+	}, 5*time.Second, 20*time.Millisecond) {
+		cmd.Process.Kill()
+		t.FailNow()
+	}
+
+	// A request in flight during the restart is drained by the old process,
+	// and requests keep being served while the processes are swapped.
+	var wg sync.WaitGroup
+	statusCode := make(chan int, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		code, _ := get("/status")
+		statusCode <- code
+	}()
+	done := make(chan struct{})
+	var failed []error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+			if _, err := get("/livez"); err != nil {
+				failed = append(failed, err)
+			}
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, cmd.Process.Signal(syscall.SIGHUP))
+	select {
+	case err := <-exited:
+		assert.NoError(t, err, "old process should exit cleanly")
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("old process did not exit after restart")
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	var restart, shutdown map[string]any
+	for _, line := range buf.Lines() {
+		switch line["msg"] {
+		case "RESTART":
+			restart = line
+		case "SHUTDOWN":
+			shutdown = line
+		}
+	}
+	require.NotNil(t, restart, "RESTART should be logged")
+	pid, ok := restart["pid"].(float64)
+	require.True(t, ok, "RESTART should log the pid of the new process")
+	defer syscall.Kill(int(pid), syscall.SIGTERM)
+
+	require.NotNil(t, shutdown, "SHUTDOWN should be logged")
+	assert.Equal(t, "restart", shutdown["reason"])
+	assert.Equal(t, http.StatusOK, <-statusCode, "in-flight request should be drained")
+	assert.Empty(t, failed, "requests should not fail during the restart")
+
+	code, err := get("/livez")
+	require.NoError(t, err, "new process should serve on the same port")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
 
 	"os"
+	"sync"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -13,6 +23,16 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
 	slogformatter "github.com/samber/slog-formatter"
+	"github.com/zate/go-template/api/internal/clientip"
+	"github.com/zate/go-template/api/internal/diagnostics"
+	"github.com/zate/go-template/api/internal/levels"
+	"github.com/zate/go-template/api/internal/listener"
+	"github.com/zate/go-template/api/internal/logctx"
+	"github.com/zate/go-template/api/internal/metrics"
+	"github.com/zate/go-template/api/internal/probes"
+	"github.com/zate/go-template/api/internal/ratelimit"
+	"github.com/zate/go-template/api/internal/transport"
+	"golang.org/x/net/http2"
 
 	"log/slog"
 )
@@ -20,13 +40,38 @@ import (
 //go:embed templates/*.tmpl
 var templates embed.FS
 
+const (
+	startTimeCtxKey = "start_time"
+	pathCtxKey      = "path"
+)
+
 // Service is the main struct for our API service
 type Service struct {
-	Logger *slog.Logger
-	Port   int
-	Server *echo.Echo
-	C      *echo.Context
-	Path   string
+	Config   Config
+	Logger   *slog.Logger
+	Port     int
+	Server   *echo.Echo
+	Admin    *echo.Echo
+	Checks   *HealthRegistry
+	Probes   *probes.Probes
+	Metrics  *metrics.Metrics
+	Tracing  *Tracing
+	Levels   *levels.Controller
+	Redactor *Redactor
+	Errors   *httpErrorHandler
+	// ClientIP resolves the client IP of requests from the headers of the trusted proxies.
+	ClientIP *clientip.Resolver
+	// RateLimiter holds the buckets of the rate limits, in memory unless it is replaced
+	// before BindRoutes, e.g. with ratelimit.NewLimiter(ratelimit.NewRedisStore(client, prefix)).
+	RateLimiter *ratelimit.Limiter
+
+	mu         sync.Mutex
+	hooks      []shutdownHook
+	transports []transport.Transport
+	stop       chan struct{}
+	stopOnce   sync.Once
+	started    time.Time
+	rateLimits []rateLimitGroup
 }
 
 type CustomValidator struct {
@@ -38,7 +83,8 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return cv.validator.Struct(i)
 }
 
-// Run starts the service
+// Run starts the service and blocks until it has been shut down gracefully,
+// either by SIGINT or SIGTERM or by calling Stop.
 func (s *Service) Run() error {
 	e, err := s.BindRoutes()
 	if err != nil {
@@ -47,18 +93,101 @@ func (s *Service) Run() error {
 	s.Server = e
 
 	listenAddress := ":" + fmt.Sprint(s.Port)
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	admin, err := s.adminServer()
+	if err != nil {
+		return err
+	}
+	h2s := s.http2Server()
+	if tlsConfig != nil {
+		e.TLSServer.TLSConfig = tlsConfig
+		if !e.DisableHTTP2 {
+			if err := http2.ConfigureServer(e.TLSServer, h2s); err != nil {
+				return err
+			}
+		}
+	}
 
-	e.Logger.Fatal(e.Start(listenAddress))
-	return nil
+	ln, lnName, err := listen(listenAddress)
+	if err != nil {
+		return err
+	}
+	listeners, names := []net.Listener{ln}, []string{lnName}
+	serving := false
+	defer func() {
+		// once serving, the listeners are closed by the shutdown of their servers
+		if !serving {
+			closeListeners(listeners)
+			s.Admin = nil
+		}
+	}()
+	if admin != nil {
+		adminLn, adminName, err := listen(s.Config.Admin.Address)
+		if err != nil {
+			return err
+		}
+		admin.Listener = adminLn
+		s.Admin = admin
+		listeners, names = append(listeners, adminLn), append(names, adminName)
+	}
+
+	// Signals are handled from now on, so that a signal received once the service
+	// is ready, e.g. a restart, does not terminate the process.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sigCh)
+
+	transports := s.getTransports()
+	errCh := make(chan error, 2+len(transports))
+	serve := func(fn func() error) {
+		go func() {
+			if err := fn(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+
+	serving = true
+	switch {
+	case tlsConfig != nil:
+		e.TLSListener = tls.NewListener(ln, tlsConfig)
+		serve(func() error { return e.StartServer(e.TLSServer) })
+	case s.Config.HTTP2.H2C:
+		e.Listener = ln
+		serve(func() error { return e.StartH2CServer(listenAddress, h2s) })
+	default:
+		e.Listener = ln
+		serve(func() error { return e.Start(listenAddress) })
+	}
+	for _, t := range transports {
+		serve(func() error { return t.Serve(e) })
+	}
+	if admin != nil {
+		serve(func() error { return admin.Start(s.Config.Admin.Address) })
+	}
+	s.Probes.SetStarted()
+	if err := listener.NotifyReady(); err != nil {
+		s.logger(subsystemRestart).LogAttrs(context.Background(), slog.LevelError, "RESTART", s.Any("error", err.Error()))
+	}
+
+	reason, err := s.wait(sigCh, errCh, listeners, names)
+	if err != nil {
+		s.Logger.LogAttrs(context.Background(), slog.LevelError, "SERVER_ERROR", s.Any("error", err.Error()))
+	}
+	return errors.Join(err, s.shutdown(reason))
 }
 
 // BindRoutes binds the routes to the service
 func (s *Service) BindRoutes() (*echo.Echo, error) {
 	e := echo.New()
-	e.HTTPErrorHandler = e.DefaultHTTPErrorHandler
+	e.HTTPErrorHandler = s.Errors.Handler
 
 	e.HideBanner = true
 	e.HidePort = true
+	e.IPExtractor = s.ClientIP.Resolve
 	e.Pre(middleware.RemoveTrailingSlash())
 	// Custom validator
 	v := validator.New()
@@ -67,16 +196,27 @@ func (s *Service) BindRoutes() (*echo.Echo, error) {
 	config := LoggingConfig{
 		WithUserAgent: true,
 		WithRequestID: true,
+		WithTraceID:   true,
+		WithSpanID:    true,
 		Message:       "REQUEST",
+		Metrics:       s.Metrics,
+		Redactor:      s.Redactor,
 	}
 	e.Use(s.ContextMiddleware)
+	e.Use(s.Tracing.Middleware())
+	e.Use(s.DebugMiddleware())
 	e.Use(NewLoggingMiddlewareWithConfig(s.Logger, config))
 	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
 		StackSize:    1 << 10, // 1 KB
 		LogLevel:     log.Lvl(slog.LevelError),
 		LogErrorFunc: s.PanicErrorFunc(),
 	}))
-	e.Use(middleware.RequestID())
+	e.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		// store the request ID in the request context, so that it is logged with it
+		RequestIDHandler: func(c echo.Context, id string) {
+			c.SetRequest(c.Request().WithContext(logctx.WithRequestID(c.Request().Context(), id)))
+		},
+	}))
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:8080"},
 		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept},
@@ -85,6 +225,7 @@ func (s *Service) BindRoutes() (*echo.Echo, error) {
 	}))
 	e.Use(middleware.Gzip())
 	e.Use(middleware.Secure())
+	e.Use(s.RateLimitMiddleware())
 
 	// TODO: Add template rendering here
 	t := &TemplateRegistry{
@@ -96,37 +237,195 @@ func (s *Service) BindRoutes() (*echo.Echo, error) {
 	root := e.Group("/")
 	root.RouteNotFound("*", s.NotFoundHandler)
 	root.GET("healthcheck", s.HealthcheckHandler)
+	root.GET("livez", echo.WrapHandler(s.Probes.LivezHandler()))
+	root.GET("readyz", echo.WrapHandler(s.Probes.ReadyzHandler()))
+	root.GET("startupz", echo.WrapHandler(s.Probes.StartupzHandler()))
+	root.GET("metrics", echo.WrapHandler(s.Metrics.Handler()))
 	root.GET("status", s.StatusHandler)
-	root.GET("debug", s.DebugHandler)
+	if s.debugEnabled() {
+		root.GET("debug", s.DebugHandler, s.debugAuth())
+	}
+	if s.Config.Admin.Token != "" {
+		root.Match([]string{http.MethodGet, http.MethodPut}, "loglevel", echo.WrapHandler(s.Levels.Handler()), s.adminAuth())
+	}
 
 	return e, nil
 }
 
-// NewService creates a new service
-func NewService(port int) (*Service, error) {
-	handlerOptions := &slog.HandlerOptions{}
-	handler := slog.NewJSONHandler(os.Stdout, handlerOptions)
+// AddTransport adds a Transport that serves the routes of the service alongside the HTTP server, e.g. HTTP/3
+// over QUIC. It must be called before Run, the transport is shut down with the HTTP server.
+func (s *Service) AddTransport(t transport.Transport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transports = append(s.transports, t)
+}
+
+// getTransports returns a copy of the transports added with AddTransport
+func (s *Service) getTransports() []transport.Transport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]transport.Transport(nil), s.transports...)
+}
+
+// http2Server returns the HTTP/2 settings of the service
+func (s *Service) http2Server() *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams: uint32(s.Config.HTTP2.MaxConcurrentStreams),
+		MaxReadFrameSize:     uint32(s.Config.HTTP2.MaxReadFrameSize),
+		IdleTimeout:          s.Server.Server.IdleTimeout,
+	}
+}
+
+// RegisterError maps the sentinel error err to the HTTP status code status in the error responses of the service
+func (s *Service) RegisterError(err error, status int) {
+	s.Errors.Register(err, status)
+}
+
+// NewService creates a new service from cfg
+func NewService(cfg Config) (*Service, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	lc, err := newLevels(cfg.Log)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := NewRedactor(cfg.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	rateLimits, err := newRateLimitGroups(cfg.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	clientIP, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	logger := newLogger(os.Stdout, lc, redactor)
+	tracing, err := newTracing(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	logger := slog.New(
-		slogformatter.NewFormatterHandler(
-			slogformatter.TimezoneConverter(time.UTC),
-			slogformatter.TimeFormatter(time.RFC3339, nil),
-		)(
-			handler,
-		),
-	)
 	newService := &Service{
-		Logger: logger,
-		Port:   port,
+		Config:   cfg,
+		Logger:   logger,
+		Port:     cfg.Port,
+		Checks:   NewHealthRegistry(defaultCheckTimeout),
+		Probes:   probes.New(),
+		Metrics:  metrics.New(),
+		Tracing:  tracing,
+		Levels:   lc,
+		Redactor: redactor,
+		Errors:   NewHttpErrorHandler(NewErrorStatusCodeMaps()),
+		stop:     make(chan struct{}),
+		started:  time.Now(),
+
+		ClientIP:    clientIP,
+		RateLimiter: ratelimit.NewLimiter(nil),
+		rateLimits:  rateLimits,
 	}
+	// Required dependencies fail the readiness probe while they are down, including
+	// those registered later
+	newService.Probes.AddReadinessCheck("checks", func(ctx context.Context) error {
+		return newService.Checks.checkRequired(ctx, newService.logger(subsystemChecks))
+	})
 	return newService, nil
 }
 
+// newLogger returns the logger of the service, that writes JSON to w at the levels of lc.
+// Records have the values of their context added and are redacted by redactor.
+func newLogger(w io.Writer, lc *levels.Controller, redactor *Redactor) *slog.Logger {
+	handlerOptions := &slog.HandlerOptions{Level: lc.Level()}
+	handler := slog.NewJSONHandler(w, handlerOptions)
+
+	return slog.New(
+		newContextHandler(
+			newRedactHandler(
+				slogformatter.NewFormatterHandler(
+					slogformatter.TimezoneConverter(time.UTC),
+					slogformatter.TimeFormatter(time.RFC3339, nil),
+				)(
+					handler,
+				),
+				redactor,
+			),
+		),
+	)
+}
+
+// adminAuth returns a middleware that requires the admin token as the bearer token of requests
+func (s *Service) adminAuth() echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(key), []byte(s.Config.Admin.Token)) == 1, nil
+		},
+	})
+}
+
+// adminServer returns the server of the diagnostics on admin.address, see diagnostics.Handler,
+// or nil if the address is not set. It requires the admin token, and without one the
+// address must be a loopback address.
+func (s *Service) adminServer() (*echo.Echo, error) {
+	address := s.Config.Admin.Address
+	if address == "" {
+		return nil, nil
+	}
+	var middlewares []echo.MiddlewareFunc
+	switch {
+	case s.Config.Admin.Token != "":
+		middlewares = append(middlewares, s.adminAuth())
+	case !diagnostics.LoopbackAddress(address):
+		return nil, fmt.Errorf("admin server: %s is not a loopback address, admin.token is required", address)
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = s.Errors.Handler
+	e.Any("/debug/*", echo.WrapHandler(diagnostics.Handler()), middlewares...)
+	return e, nil
+}
+
+// ContextMiddleware stores the request scoped values used by the handlers, such as
+// the start time and the transformed path, in the echo.Context of the request. The
+// client IP is stored in the request context, and logged with it as client-ip.
 func (s *Service) ContextMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		c.Set("start_time", time.Now())
-		s.Path = transformPath(c.Path())
-		s.C = &c
+		c.Set(startTimeCtxKey, time.Now())
+		c.Set(pathCtxKey, transformPath(c.Path()))
+		req := c.Request()
+		ip := s.ClientIP.Resolve(req)
+		ctx := logctx.WithAttrs(clientip.NewContext(req.Context(), ip), slog.String(logKeyClientIP, ip))
+		c.SetRequest(req.WithContext(ctx))
 		return next(c)
 	}
 }
+
+// clientIP returns the client IP of the request in c, as stored by ContextMiddleware.
+// It falls back to resolving it if the middleware has not been run.
+func (s *Service) clientIP(c echo.Context) string {
+	if ip := clientip.FromContext(c.Request().Context()); ip != "" {
+		return ip
+	}
+	return s.ClientIP.Resolve(c.Request())
+}
+
+// StartTime returns the time the request in c was received by ContextMiddleware.
+// It falls back to the current time if the middleware has not been run.
+func StartTime(c echo.Context) time.Time {
+	if startTime, ok := c.Get(startTimeCtxKey).(time.Time); ok {
+		return startTime
+	}
+	return time.Now()
+}
+
+// RequestPath returns the transformed route path of the request in c, as set by ContextMiddleware.
+func RequestPath(c echo.Context) string {
+	if path, ok := c.Get(pathCtxKey).(string); ok {
+		return path
+	}
+	return transformPath(c.Path())
+}
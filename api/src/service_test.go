@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer that is safe to write to from concurrent requests.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Lines() []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := map[string]any{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// newTestService returns a Service with its routes bound and a logger that writes JSON to the returned buffer.
+func newTestService(t *testing.T) (*Service, *echo.Echo, *syncBuffer) {
+	t.Helper()
+
+	s, err := NewService(DefaultConfig())
+	require.NoError(t, err)
+
+	buf := &syncBuffer{}
+	s.Logger = newLogger(buf, s.Levels, s.Redactor)
+	for _, name := range []string{"service1", "service2", "service3"} {
+		require.NoError(t, s.Checks.Register(NewFuncCheck(name, mockCheck)))
+	}
+
+	e, err := s.BindRoutes()
+	require.NoError(t, err)
+	s.Server = e
+	return s, e, buf
+}
+
+func TestService_ConcurrentRequests(t *testing.T) {
+	_, e, buf := newTestService(t)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	// paths maps the request path to the custom attribute group its handler logs.
+	paths := map[string]string{
+		"/status":      "Status",
+		"/healthcheck": "Healthcheck",
+	}
+	expected := sync.Map{}
+
+	const requests = 50
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		for path := range paths {
+			wg.Add(1)
+			go func(i int, path string) {
+				defer wg.Done()
+				id := fmt.Sprintf("%s-%d", path, i)
+				expected.Store(id, path)
+
+				req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+				if !assert.NoError(t, err) {
+					return
+				}
+				req.Header.Set(echo.HeaderXRequestID, id)
+				resp, err := http.DefaultClient.Do(req)
+				if !assert.NoError(t, err) {
+					return
+				}
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}(i, path)
+		}
+	}
+	wg.Wait()
+
+	lines := buf.Lines()
+	require.Len(t, lines, requests*len(paths))
+
+	for _, line := range lines {
+		id, ok := line["id"].(string)
+		require.True(t, ok, "log line without request id: %v", line)
+
+		v, ok := expected.Load(id)
+		require.True(t, ok, "unexpected request id %q", id)
+		path := v.(string)
+
+		request, ok := line["request"].(map[string]any)
+		require.True(t, ok)
+		assert.Equal(t, path, request["path"], "request %s logged with wrong path", id)
+
+		for other, group := range paths {
+			_, found := line[group]
+			assert.Equal(t, other == path, found, "request %s has unexpected custom attributes for %s", id, group)
+		}
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	s, e, _ := newTestService(t)
+
+	var first, second echo.Context
+	handler := s.ContextMiddleware(func(c echo.Context) error { return nil })
+
+	first = e.NewContext(httptest.NewRequest(http.MethodGet, "/status", nil), httptest.NewRecorder())
+	require.NoError(t, handler(first))
+	second = e.NewContext(httptest.NewRequest(http.MethodGet, "/healthcheck", nil), httptest.NewRecorder())
+	require.NoError(t, handler(second))
+
+	assert.False(t, StartTime(first).After(StartTime(second)))
+	assert.Equal(t, first.Get(startTimeCtxKey), StartTime(first))
+	assert.Equal(t, second.Get(startTimeCtxKey), StartTime(second))
+}
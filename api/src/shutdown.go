@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/zate/go-template/api/internal/listener"
+)
+
+// shutdownHook is a function run during shutdown, after in-flight requests have drained
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// OnShutdown registers fn to be run during graceful shutdown, after in-flight requests have drained.
+// Hooks run in the order they were registered, e.g. to flush buffers before closing a DB pool.
+func (s *Service) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Stop makes Run shut the service down gracefully as if it had received a signal
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// wait blocks until SIGINT or SIGTERM is received on sigCh, Stop is called or the server fails.
+// It returns the reason for the shutdown and the error the server failed with, if any.
+// On SIGHUP or SIGUSR2 the listeners, named by names, are handed off to a new process, and
+// wait returns once it is ready. If the restart fails it is logged and the service keeps serving.
+// SIGUSR1 toggles debug logging.
+func (s *Service) wait(sigCh <-chan os.Signal, errCh <-chan error, listeners []net.Listener, names []string) (string, error) {
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGUSR1 {
+				s.toggleDebug()
+				continue
+			}
+			if sig != syscall.SIGHUP && sig != syscall.SIGUSR2 {
+				return sig.String(), nil
+			}
+			log := s.logger(subsystemRestart)
+			log.LogAttrs(context.Background(), slog.LevelDebug, "RESTART", slog.String("signal", sig.String()), slog.Any("listeners", names))
+			pid, err := listener.Restart(listeners, names, s.Config.Shutdown.RestartTimeout)
+			if err != nil {
+				log.LogAttrs(context.Background(), slog.LevelError, "RESTART", s.Any("error", err.Error()))
+				continue
+			}
+			log.LogAttrs(context.Background(), slog.LevelInfo, "RESTART", slog.Int("pid", pid))
+			return "restart", nil
+		case <-s.stop:
+			return "stopped", nil
+		case err := <-errCh:
+			return "server error", err
+		}
+	}
+}
+
+// shutdown fails the readiness probe, waits for the configured delay so load balancers can
+// stop sending traffic, drains in-flight requests and runs the shutdown hooks in order.
+// It logs a SHUTDOWN event with the reason and duration and returns the errors joined.
+func (s *Service) shutdown(reason string) error {
+	start := time.Now()
+	s.Probes.SetShuttingDown()
+
+	if s.Config.Shutdown.Delay > 0 {
+		time.Sleep(s.Config.Shutdown.Delay)
+	}
+
+	var errs []error
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.Config.Shutdown.Timeout)
+	defer cancel()
+	if err := s.Server.Shutdown(drainCtx); err != nil {
+		errs = append(errs, fmt.Errorf("drain: %w", err))
+	}
+	for _, t := range s.getTransports() {
+		if err := t.Shutdown(drainCtx); err != nil {
+			errs = append(errs, fmt.Errorf("drain transport: %w", err))
+		}
+	}
+	if s.Admin != nil {
+		if err := s.Admin.Shutdown(drainCtx); err != nil {
+			errs = append(errs, fmt.Errorf("drain admin: %w", err))
+		}
+	}
+
+	s.mu.Lock()
+	hooks := make([]shutdownHook, len(s.hooks))
+	copy(hooks, s.hooks)
+	s.mu.Unlock()
+
+	hookCtx, cancel := context.WithTimeout(context.Background(), s.Config.Shutdown.Timeout)
+	defer cancel()
+	for _, hook := range hooks {
+		if err := hook.fn(hookCtx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %s: %w", hook.name, err))
+		}
+	}
+	// Spans of the drained requests and the hooks are exported last.
+	if err := s.Tracing.Shutdown(hookCtx); err != nil {
+		errs = append(errs, fmt.Errorf("tracing: %w", err))
+	}
+
+	err := errors.Join(errs...)
+	attrs := []slog.Attr{
+		slog.String("reason", reason),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("hooks", len(hooks)),
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	s.Logger.LogAttrs(context.Background(), level, "SHUTDOWN", attrs...)
+	return err
+}
+
+// toggleDebug ends debug logging if it is enabled, or enables it for the configured duration.
+// It logs a LOG_LEVEL event with the time debug logging ends.
+func (s *Service) toggleDebug() {
+	until := s.Levels.ToggleDebug(s.Config.Log.DebugDuration)
+	if until.IsZero() {
+		s.Logger.LogAttrs(context.Background(), slog.LevelInfo, "LOG_LEVEL", slog.Bool("debug", false))
+		return
+	}
+	s.Logger.LogAttrs(context.Background(), slog.LevelInfo, "LOG_LEVEL", slog.Bool("debug", true), slog.Time("until", until))
+}
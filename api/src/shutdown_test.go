@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freePort returns a TCP port that is free to listen on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// runTestService runs s in the background and waits until it serves requests. It returns the base URL and the result of Run.
+func runTestService(t *testing.T, s *Service) (string, <-chan error) {
+	t.Helper()
+	s.Port = freePort(t)
+	url := fmt.Sprintf("http://127.0.0.1:%d", s.Port)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run() }()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url + "/livez")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	return url, runErr
+}
+
+func TestService_GracefulShutdown(t *testing.T) {
+	s, _, buf := newTestService(t)
+	s.Config.Shutdown.Delay = 200 * time.Millisecond
+	s.Config.Shutdown.Timeout = 5 * time.Second
+
+	inFlight := make(chan struct{})
+	require.NoError(t, s.Checks.Register(NewFuncCheck("slow", func(ctx context.Context) error {
+		close(inFlight)
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	}), WithCheckTimeout(time.Second)))
+
+	var mu sync.Mutex
+	var order []string
+	for _, name := range []string{"flush", "close-db"} {
+		name := name
+		s.OnShutdown(name, func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			if name == "close-db" {
+				return errors.New("pool busy")
+			}
+			return nil
+		})
+	}
+
+	url, runErr := runTestService(t, s)
+
+	statusCode := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url + "/status")
+		if err != nil {
+			statusCode <- 0
+			return
+		}
+		resp.Body.Close()
+		statusCode <- resp.StatusCode
+	}()
+	<-inFlight
+	s.Stop()
+
+	// readiness fails during the delay before draining starts
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, <-statusCode, "in-flight request should be drained")
+
+	err := <-runErr
+	assert.ErrorContains(t, err, "shutdown hook close-db: pool busy")
+	assert.Equal(t, []string{"flush", "close-db"}, order)
+
+	var shutdown map[string]any
+	for _, line := range buf.Lines() {
+		if line["msg"] == "SHUTDOWN" {
+			shutdown = line
+		}
+	}
+	require.NotNil(t, shutdown, "SHUTDOWN should be logged")
+	assert.Equal(t, "stopped", shutdown["reason"])
+	assert.Equal(t, "ERROR", shutdown["level"])
+	assert.GreaterOrEqual(t, shutdown["duration"], float64(s.Config.Shutdown.Delay))
+
+	_, err = http.Get(url + "/livez")
+	assert.Error(t, err, "server should no longer accept connections")
+}
+
+func TestService_Signal(t *testing.T) {
+	s, _, buf := newTestService(t)
+	s.Config.Shutdown.Timeout = 5 * time.Second
+
+	// the signal is sent as soon as the service serves, it is handled from
+	// before the service is ready instead of terminating the process
+	_, runErr := runTestService(t, s)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-runErr:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service did not shut down")
+	}
+
+	var shutdown map[string]any
+	for _, line := range buf.Lines() {
+		if line["msg"] == "SHUTDOWN" {
+			shutdown = line
+		}
+	}
+	require.NotNil(t, shutdown, "SHUTDOWN should be logged")
+	assert.Equal(t, syscall.SIGTERM.String(), shutdown["reason"])
+}
+
+func TestService_Run_ListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	s, _, _ := newTestService(t)
+	s.Port = ln.Addr().(*net.TCPAddr).Port
+	assert.Error(t, s.Run())
+}
+
+func TestService_Run_AdminListenError(t *testing.T) {
+	adminLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer adminLn.Close()
+
+	s, _, _ := newTestService(t)
+	s.Port = freePort(t)
+	s.Config.Admin.Address = adminLn.Addr().String()
+	assert.Error(t, s.Run())
+	assert.Nil(t, s.Admin, "the admin server should not be kept when Run fails")
+
+	// the listener of the service is closed, so its address can be listened on again
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
+	require.NoError(t, err)
+	ln.Close()
+}
@@ -69,6 +69,8 @@ func TestAPI(t *testing.T) {
 				method:   "GET",
 				endpoint: "/debug",
 				body:     nil,
+				// the client is not on the loopback interface of the container
+				headers: map[string]string{"Authorization": "Bearer " + os.Getenv("APP_ADMIN_TOKEN")},
 			},
 			respCode:   http.StatusOK,
 			statusOnly: true,
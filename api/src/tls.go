@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/zate/go-template/api/internal/devcert"
+)
+
+// tlsConfig returns the tls.Config for HTTPS, or nil if it is not configured. In development mode
+// the development certificate is created if needed, and the path to its CA is logged so it can be trusted.
+func (s *Service) tlsConfig() (*tls.Config, error) {
+	c := s.Config.TLS
+	if c.Dev {
+		certs, err := devcert.Ensure(c.DevCertDir)
+		if err != nil {
+			return nil, err
+		}
+		c.CertFile, c.KeyFile = certs.CertFile, certs.KeyFile
+		caFile, err := filepath.Abs(certs.CAFile)
+		if err != nil {
+			caFile = certs.CAFile
+		}
+		s.Logger.LogAttrs(context.Background(), slog.LevelInfo, "DEV_TLS",
+			slog.String("ca_file", caFile),
+			slog.Bool("created", certs.Created),
+			slog.String("hint", "trust the CA to avoid certificate warnings"),
+		)
+	}
+	if c.CertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	nextProtos := []string{"http/1.1"}
+	if !s.Server.DisableHTTP2 {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   nextProtos,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Run_DevTLS(t *testing.T) {
+	s, _, buf := newTestService(t)
+	dir := t.TempDir()
+	s.Config.TLS.Dev = true
+	s.Config.TLS.DevCertDir = dir
+	s.Port = freePort(t)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run() }()
+
+	var ca []byte
+	var err error
+	require.Eventually(t, func() bool {
+		ca, err = os.ReadFile(filepath.Join(dir, "ca.crt"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(ca))
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+	url := fmt.Sprintf("https://localhost:%d/livez", s.Port)
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = client.Get(url)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor, "HTTP/2 should be negotiated over TLS")
+
+	s.Stop()
+	require.NoError(t, <-runErr)
+
+	var devTLS map[string]any
+	for _, line := range buf.Lines() {
+		if line["msg"] == "DEV_TLS" {
+			devTLS = line
+		}
+	}
+	require.NotNil(t, devTLS, "DEV_TLS should be logged")
+	assert.Equal(t, filepath.Join(dir, "ca.crt"), devTLS["ca_file"])
+	assert.Equal(t, true, devTLS["created"])
+}
+
+func TestConfig_Validate_TLS(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TLS.CertFile = "tls.crt"
+	assert.ErrorContains(t, cfg.Validate(), "KeyFile")
+
+	cfg.TLS.KeyFile = "tls.key"
+	assert.NoError(t, cfg.Validate())
+}
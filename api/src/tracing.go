@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer of the service, the instrumentation scope of its spans.
+const tracerName = "github.com/zate/go-template/api"
+
+// Span exporters of TracingConfig.
+const (
+	tracingExporterNone   = "none"
+	tracingExporterStdout = "stdout"
+	tracingExporterOTLP   = "otlp"
+)
+
+// tracePropagator extracts and injects the W3C trace context and baggage.
+var tracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Tracing holds the tracer provider of the service.
+type Tracing struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// NewTracing returns Tracing that samples new traces with ratio and exports the spans
+// with exporter. If exporter is nil spans are not exported, but trace and span IDs are
+// still generated, logged and propagated.
+func NewTracing(res *resource.Resource, ratio float64, exporter sdktrace.SpanExporter) *Tracing {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+	return &Tracing{
+		provider: provider,
+		tracer:   provider.Tracer(tracerName),
+	}
+}
+
+// newSpanExporter returns the span exporter configured by cfg, or nil if spans are not exported.
+func newSpanExporter(ctx context.Context, cfg TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case tracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case tracingExporterOTLP:
+		var options []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, options...)
+	case tracingExporterNone, "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("tracing: unsupported exporter %q", cfg.Exporter)
+	}
+}
+
+// newTracing returns the Tracing configured by cfg, with the service name, version and
+// environment as the resource of its spans.
+func newTracing(cfg Config) (*Tracing, error) {
+	exporter, err := newSpanExporter(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+		semconv.DeploymentEnvironment(cfg.Environment),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+	return NewTracing(res, cfg.Tracing.SampleRatio, exporter), nil
+}
+
+// Shutdown exports the spans that have not been exported yet and stops the exporter.
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
+
+// Middleware returns a middleware that starts a server span for each request, named by
+// its method and route. The trace context and baggage are extracted from the request
+// headers, the status and error of the response are recorded on the span.
+func (t *Tracing) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := tracePropagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			ctx, span := t.tracer.Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethod(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+					semconv.ServerAddress(req.Host),
+				),
+			)
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				span.RecordError(err)
+			}
+
+			status := c.Response().Status
+			httpErr := new(echo.HTTPError)
+			if err != nil && errors.As(err, &httpErr) {
+				status = httpErr.Code
+			}
+			span.SetAttributes(semconv.HTTPStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			return err
+		}
+	}
+}
+
+// tracingTransport is an http.RoundTripper that traces outgoing requests.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+// NewTracingTransport returns an http.RoundTripper that starts a client span for each
+// request, as a child of the span in the context of the request, and propagates the
+// trace context and baggage in the request headers. Requests without a span in their
+// context are sent as is.
+func NewTracingTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base}
+}
+
+// RoundTrip sends the request in a client span.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := trace.SpanFromContext(req.Context())
+	if !parent.SpanContext().IsValid() {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, span := parent.TracerProvider().Tracer(tracerName).Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	tracePropagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// startCheckSpan starts a span for the check name, as a child of the span in ctx.
+func startCheckSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "check "+name,
+		trace.WithAttributes(attribute.String("check.name", name)),
+	)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracedTestService returns a test service that exports its spans to the returned in-memory exporter.
+func newTracedTestService(t *testing.T) (*Service, *echo.Echo, *syncBuffer, *tracetest.InMemoryExporter) {
+	t.Helper()
+	s, _, buf := newTestService(t)
+	exporter := tracetest.NewInMemoryExporter()
+	s.Tracing = NewTracing(resource.Empty(), 1, exporter)
+	e, err := s.BindRoutes()
+	require.NoError(t, err)
+	s.Server = e
+	return s, e, buf, exporter
+}
+
+// exportedSpans flushes the spans of s and returns them by name.
+func exportedSpans(t *testing.T, s *Service, exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	t.Helper()
+	require.NoError(t, s.Tracing.provider.ForceFlush(context.Background()))
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func TestTracing_Middleware(t *testing.T) {
+	s, e, buf, exporter := newTracedTestService(t)
+	e.GET("/fail", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusInternalServerError, "boom")
+	})
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantSpan   string
+		wantStatus codes.Code
+	}{
+		{name: "success", path: "/livez", wantSpan: "GET /livez", wantStatus: codes.Unset},
+		{name: "server error", path: "/fail", wantSpan: "GET /fail", wantStatus: codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			tracePropagator.Inject(trace.ContextWithRemoteSpanContext(context.Background(), parent), propagation.HeaderCarrier(req.Header))
+			e.ServeHTTP(httptest.NewRecorder(), req)
+
+			span, ok := exportedSpans(t, s, exporter)[tt.wantSpan]
+			require.True(t, ok, "span %q should be exported", tt.wantSpan)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+			assert.Equal(t, parent.TraceID(), span.SpanContext.TraceID(), "span should continue the trace of the caller")
+			assert.Equal(t, parent.SpanID(), span.Parent.SpanID())
+			assert.Equal(t, tt.wantStatus, span.Status.Code)
+		})
+	}
+
+	// the trace and span IDs are logged with the request
+	var logged bool
+	for _, line := range buf.Lines() {
+		if line["msg"] == "REQUEST" {
+			assert.Equal(t, parent.TraceID().String(), line["trace-id"])
+			assert.NotEqual(t, trace.SpanID{}.String(), line["span-id"])
+			logged = true
+		}
+	}
+	assert.True(t, logged, "request should be logged")
+}
+
+func TestTracing_StatusChecksPropagate(t *testing.T) {
+	var gotTraceparent, gotBaggage string
+	dependency := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceparent = r.Header.Get("traceparent")
+		gotBaggage = r.Header.Get("baggage")
+	}))
+	defer dependency.Close()
+
+	s, _, _ := newTestService(t)
+	require.NoError(t, s.Checks.Register(NewHTTPCheck("dependency", dependency.URL, http.StatusOK)))
+	exporter := tracetest.NewInMemoryExporter()
+	s.Tracing = NewTracing(resource.Empty(), 1, exporter)
+	e, err := s.BindRoutes()
+	require.NoError(t, err)
+	s.Server = e
+
+	member, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	bag, err := baggage.New(member)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	tracePropagator.Inject(baggage.ContextWithBaggage(context.Background(), bag), propagation.HeaderCarrier(req.Header))
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exportedSpans(t, s, exporter)
+	server, ok := spans["GET /status"]
+	require.True(t, ok, "server span should be exported")
+	check, ok := spans["check dependency"]
+	require.True(t, ok, "check span should be exported")
+	client, ok := spans[http.MethodGet]
+	require.True(t, ok, "client span should be exported")
+
+	assert.Equal(t, server.SpanContext.SpanID(), check.Parent.SpanID())
+	assert.Equal(t, check.SpanContext.SpanID(), client.Parent.SpanID())
+	assert.Equal(t, trace.SpanKindClient, client.SpanKind)
+
+	outgoing := tracePropagator.Extract(context.Background(), propagation.HeaderCarrier{"Traceparent": {gotTraceparent}})
+	assert.Equal(t, client.SpanContext.TraceID(), trace.SpanContextFromContext(outgoing).TraceID(), "trace context should be propagated to the dependency")
+	assert.Equal(t, client.SpanContext.SpanID(), trace.SpanContextFromContext(outgoing).SpanID())
+	assert.Equal(t, "tenant=acme", gotBaggage, "baggage should be propagated to the dependency")
+}
+
+func TestNewSpanExporter(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantNil  bool
+		wantErr  string
+	}{
+		{name: "none", exporter: tracingExporterNone, wantNil: true},
+		{name: "stdout", exporter: tracingExporterStdout},
+		{name: "otlp", exporter: tracingExporterOTLP},
+		{name: "unsupported", exporter: "zipkin", wantErr: `tracing: unsupported exporter "zipkin"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, err := newSpanExporter(context.Background(), TracingConfig{Exporter: tt.exporter, Endpoint: "localhost:4318", Insecure: true})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNil, exporter == nil)
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"log/slog"
-	"os"
 	"regexp"
 	"runtime"
 	"strings"
@@ -14,40 +14,31 @@ import (
 	"golang.org/x/text/language"
 )
 
-// getEnv is a function to get an environment variable or return a default value
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
-}
-
 // getDebugInfo is a function to get debug information from runtime.Caller to add to the logging output
 func getDebugInfo() (string, string, int) {
 	pc, filename, line, _ := runtime.Caller(1)
 	return runtime.FuncForPC(pc).Name(), filename, line
 }
 
-// Log is a function to log messages to the console
-func (s *Service) Log(level string, msg string, args ...any) {
-	if Debug {
+// Log is a function to log messages to the console, with the request ID and trace
+// of ctx added by the ContextHandler of the logger
+func (s *Service) Log(ctx context.Context, level string, msg string, args ...any) {
+	if s.Logger.Enabled(ctx, slog.LevelDebug) {
 		_, filename, line, _ := runtime.Caller(1)
 		args = append(args, "filename", filename, "line", line)
 	}
 
 	switch level {
 	case "debug":
-		if Debug {
-			s.Logger.Info(msg, args...)
-		}
+		s.Logger.DebugContext(ctx, msg, args...)
 	case "info":
-		s.Logger.Info(msg, args...)
+		s.Logger.InfoContext(ctx, msg, args...)
 	case "warn":
-		s.Logger.Warn(msg, args...)
+		s.Logger.WarnContext(ctx, msg, args...)
 	case "error":
-		s.Logger.Error(msg, args...)
+		s.Logger.ErrorContext(ctx, msg, args...)
 	default:
-		s.Logger.Info(msg, args...)
+		s.Logger.InfoContext(ctx, msg, args...)
 	}
 }
 
@@ -71,14 +62,14 @@ func TrackTime(pre time.Time) time.Duration {
 	return time.Duration(time.Since(pre).Milliseconds())
 }
 
-// logCustomAttr is a function to log custom attributes to the log
-func (s *Service) logCustomAttr(groupName string, data map[string]interface{}) {
+// logCustomAttr is a function to log custom attributes to the log of the request in c
+func (s *Service) logCustomAttr(c echo.Context, groupName string, data map[string]interface{}) {
 	var attrs []any
 	for key, value := range data {
 		attrs = append(attrs, s.Any(key, value))
 	}
 
-	if os.Getenv("MY_ENVTYPE") != "staging" {
+	if s.Config.Environment != "staging" {
 		if data["error"] != nil {
 			pc, filename, line, _ := runtime.Caller(1)
 			funcName := runtime.FuncForPC(pc).Name()
@@ -88,7 +79,7 @@ func (s *Service) logCustomAttr(groupName string, data map[string]interface{}) {
 
 	group := slog.Group(groupName, attrs...)
 
-	AddCustomAttributes(*s.C, group)
+	AddCustomAttributes(c, group)
 }
 
 // logCustomAttr is a function to log custom attributes to the log
@@ -98,7 +89,7 @@ func (s *Service) genCustomAttr(groupName string, data map[string]interface{}) s
 		attrs = append(attrs, s.Any(key, value))
 	}
 
-	if os.Getenv("MY_ENVTYPE") != "staging" {
+	if s.Config.Environment != "staging" {
 		if data["error"] != nil {
 			pc, filename, line, _ := runtime.Caller(1)
 			funcName := runtime.FuncForPC(pc).Name()
@@ -108,19 +99,9 @@ func (s *Service) genCustomAttr(groupName string, data map[string]interface{}) s
 	return slog.Group(groupName, attrs...)
 }
 
-func (s *Service) AddCustomAttributes(attr slog.Attr) {
-	c := *s.C
-	v := c.Get(customAttributesCtxKey)
-	if v == nil {
-		c.Set(customAttributesCtxKey, []slog.Attr{attr})
-		return
-	}
-
-	switch attrs := v.(type) {
-	case []slog.Attr:
-		c.Set(customAttributesCtxKey, append(attrs, attr))
-	}
-	s.C = &c
+// AddCustomAttributes adds attr to the custom attributes logged for the request in c
+func (s *Service) AddCustomAttributes(c echo.Context, attr slog.Attr) {
+	AddCustomAttributes(c, attr)
 }
 
 // Utility function to transform the path string
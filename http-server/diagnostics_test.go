@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zate/go-templates/http-server/internal/listener"
+)
+
+func TestServer_DiagnosticsRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		listener   listener.Config
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{name: "loopback listener", listener: listener.Config{Network: listener.NetworkTCP, Address: "127.0.0.1:9090"}, wantStatus: http.StatusOK},
+		{name: "localhost listener", listener: listener.Config{Network: listener.NetworkTCP, Address: "localhost:9090"}, wantStatus: http.StatusOK},
+		{name: "unix socket", listener: listener.Config{Network: listener.NetworkUnix, Address: "/tmp/admin.sock"}, wantStatus: http.StatusOK},
+		{name: "public listener without token", listener: listener.Config{Network: listener.NetworkTCP, Address: ":9090"}, wantStatus: http.StatusNotFound},
+		{name: "public listener with wrong token", listener: listener.Config{Network: listener.NetworkTCP, Address: ":9090"}, token: "secret", header: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "public listener with token", listener: listener.Config{Network: listener.NetworkTCP, Address: "0.0.0.0:9090"}, token: "secret", header: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "loopback listener with token", listener: listener.Config{Network: listener.NetworkTCP, Address: "[::1]:9090"}, token: "secret", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			admin := test.listener
+			s := NewServer(WithOptions(Options{
+				Router:        http.NewServeMux(),
+				Log:           &mockLogger{logs: &[]string{}},
+				AdminToken:    test.token,
+				AdminListener: &admin,
+			}))
+			s.adminRoutes()
+
+			req := httptest.NewRequest("GET", "/debug/runtime", nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+			rec := httptest.NewRecorder()
+			s.admin.router.ServeHTTP(rec, req)
+			if rec.Code != test.wantStatus {
+				t.Errorf("GET /debug/runtime = %d; want %d", rec.Code, test.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP2Options holds the HTTP/2 configuration for the server.
+type HTTP2Options struct {
+	// H2C serves HTTP/2 without TLS (h2c) alongside HTTP/1.1, e.g. for
+	// service-to-service traffic behind a service mesh that terminates TLS.
+	H2C bool
+	// MaxConcurrentStreams is the number of concurrent streams per connection,
+	// defaults to 250.
+	MaxConcurrentStreams uint32
+	// MaxReadFrameSize is the largest frame the server reads, between 16KiB
+	// and 16MiB, defaults to 1MiB.
+	MaxReadFrameSize uint32
+}
+
+// configureHTTP2 configures HTTP/2 with options on the http.Server of the server
+// and returns handler, wrapped to serve h2c if it is enabled.
+func (s server) configureHTTP2(handler http.Handler, options HTTP2Options) (http.Handler, error) {
+	h2s := &http2.Server{
+		MaxConcurrentStreams: options.MaxConcurrentStreams,
+		MaxReadFrameSize:     options.MaxReadFrameSize,
+		IdleTimeout:          s.httpServer.IdleTimeout,
+	}
+	if s.httpServer.TLSConfig != nil {
+		if err := http2.ConfigureServer(s.httpServer, h2s); err != nil {
+			return nil, err
+		}
+	}
+	if options.H2C {
+		handler = h2c.NewHandler(handler, h2s)
+	}
+	return handler, nil
+}
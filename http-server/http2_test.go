@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/transport"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/http2"
+)
+
+func TestServer_ConfigureHTTP2(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			options HTTP2Options
+			client  func(addr string) *http.Client
+		}
+		want struct {
+			proto string
+			err   bool
+		}
+	}{
+		{
+			name: "h2c with prior knowledge",
+			input: struct {
+				options HTTP2Options
+				client  func(addr string) *http.Client
+			}{
+				options: HTTP2Options{H2C: true},
+				client:  newH2CClient,
+			},
+			want: struct {
+				proto string
+				err   bool
+			}{proto: "HTTP/2.0"},
+		},
+		{
+			name: "h2c serves HTTP/1.1",
+			input: struct {
+				options HTTP2Options
+				client  func(addr string) *http.Client
+			}{
+				options: HTTP2Options{H2C: true},
+				client: func(addr string) *http.Client {
+					return &http.Client{}
+				},
+			},
+			want: struct {
+				proto string
+				err   bool
+			}{proto: "HTTP/1.1"},
+		},
+		{
+			name: "h2c disabled",
+			input: struct {
+				options HTTP2Options
+				client  func(addr string) *http.Client
+			}{
+				options: HTTP2Options{},
+				client:  newH2CClient,
+			},
+			want: struct {
+				proto string
+				err   bool
+			}{err: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := NewServer(WithOptions(Options{
+				Router: http.NewServeMux(),
+				Log:    &mockLogger{logs: &[]string{}},
+			}))
+			handler, err := srv.configureHTTP2(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			}), test.input.options)
+			if err != nil {
+				t.Fatalf("configureHTTP2() = unexpected error: %v", err)
+			}
+
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			resp, err := test.input.client(ts.Listener.Addr().String()).Get(ts.URL)
+			if test.want.err {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("Get() = nil; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() = unexpected error: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if diff := cmp.Diff(test.want.proto, string(body)); diff != "" {
+				t.Errorf("Get() = unexpected protocol (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestServer_ConfigureHTTP2_Settings(t *testing.T) {
+	srv := NewServer(WithOptions(Options{
+		Router: http.NewServeMux(),
+		Log:    &mockLogger{logs: &[]string{}},
+	}))
+	handler, err := srv.configureHTTP2(http.NotFoundHandler(), HTTP2Options{H2C: true, MaxConcurrentStreams: 10, MaxReadFrameSize: 1 << 16})
+	if err != nil {
+		t.Fatalf("configureHTTP2() = unexpected error: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		t.Fatal(err)
+	}
+	framer := http2.NewFramer(conn, conn)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := framer.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, ok := frame.(*http2.SettingsFrame)
+	if !ok {
+		t.Fatalf("ReadFrame() = %T; want *http2.SettingsFrame", frame)
+	}
+
+	got := map[http2.SettingID]uint32{}
+	settings.ForeachSetting(func(s http2.Setting) error {
+		got[s.ID] = s.Val
+		return nil
+	})
+	want := map[http2.SettingID]uint32{
+		http2.SettingMaxConcurrentStreams: 10,
+		http2.SettingMaxFrameSize:         1 << 16,
+	}
+	for id, val := range want {
+		if got[id] != val {
+			t.Errorf("setting %v = %d; want %d", id, got[id], val)
+		}
+	}
+}
+
+func TestServer_Start_Transports(t *testing.T) {
+	logs := []string{}
+	mock := &mockTransport{shutdown: make(chan struct{})}
+	srv := NewServer(WithOptions(Options{
+		Router: http.NewServeMux(),
+		Log: &mockLogger{
+			logs: &logs,
+		},
+		Host:       "localhost",
+		Port:       strconv.Itoa(freeTestPort(t)),
+		Transports: []transport.Transport{mock},
+	}))
+
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	}()
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start() = unexpected error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	mock.getHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/livez", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("transport handler status = %d; want %d", rr.Code, http.StatusOK)
+	}
+	select {
+	case <-mock.shutdown:
+	default:
+		t.Errorf("Shutdown() was not called on the transport")
+	}
+}
+
+func TestServer_Start_TransportError(t *testing.T) {
+	logs := []string{}
+	mock := &mockTransport{shutdown: make(chan struct{}), err: errors.New("listen udp: address already in use")}
+	srv := NewServer(WithOptions(Options{
+		Router: http.NewServeMux(),
+		Log: &mockLogger{
+			logs: &logs,
+		},
+		Host:       "localhost",
+		Port:       strconv.Itoa(freeTestPort(t)),
+		Transports: []transport.Transport{mock},
+	}))
+
+	err := srv.Start()
+	if err == nil || err.Error() != mock.err.Error() {
+		t.Fatalf("Start() = %v; want %v", err, mock.err)
+	}
+	want := []string{"Server failed.", "error", mock.err.Error(), "Server shutdown.", "reason", "server error"}
+	start := slices.Index(logs, "Server failed.")
+	if start < 0 || start+len(want) > len(logs) {
+		t.Fatalf("Start() = missing failure log in %v", logs)
+	}
+	if diff := cmp.Diff(want, logs[start:start+len(want)]); diff != "" {
+		t.Errorf("Start() = unexpected logs (-want +got):\n%s\n", diff)
+	}
+	select {
+	case <-mock.shutdown:
+	default:
+		t.Errorf("Shutdown() was not called on the transport")
+	}
+}
+
+// newH2CClient returns a client that speaks HTTP/2 without TLS with prior knowledge.
+func newH2CClient(addr string) *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+		Timeout: time.Second,
+	}
+}
+
+func freeTestPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+type mockTransport struct {
+	mu       sync.Mutex
+	handler  http.Handler
+	shutdown chan struct{}
+	err      error
+}
+
+func (m *mockTransport) Serve(handler http.Handler) error {
+	m.mu.Lock()
+	m.handler = handler
+	m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	<-m.shutdown
+	return http.ErrServerClosed
+}
+
+func (m *mockTransport) Shutdown(ctx context.Context) error {
+	close(m.shutdown)
+	return nil
+}
+
+func (m *mockTransport) getHandler() http.Handler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.handler
+}
@@ -0,0 +1,194 @@
+// Package clientip resolves the IP of the client of a request, honoring the forwarding
+// headers of trusted proxies only.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// contextKey is the key of the client IP stored in a context.Context.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx with the client IP of the request.
+func NewContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ip)
+}
+
+// FromContext returns the client IP stored in ctx, or an empty string.
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(contextKey{}).(string)
+	return ip
+}
+
+// Resolver resolves the IP of the client of a request. The forwarding headers
+// Forwarded, X-Forwarded-For and X-Real-Ip are only honored if the peer of the request
+// is a trusted proxy, as they can be set by any client. The zero Resolver trusts no
+// proxies and resolves the IP of the peer.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver returns a Resolver that trusts the proxies in the CIDRs, e.g. 10.0.0.0/8,
+// or with the IPs trusted. Without any, the IP of the peer is used.
+func NewResolver(trusted []string) (*Resolver, error) {
+	c := &Resolver{}
+	for _, cidr := range trusted {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			ip, ipErr := netip.ParseAddr(cidr)
+			if ipErr != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen())
+		}
+		c.trusted = append(c.trusted, prefix.Masked())
+	}
+	return c, nil
+}
+
+// trusts reports whether ip is the address of a trusted proxy.
+func (c *Resolver) trusts(ip netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the IP of the client of r, or an empty string if the address of the
+// peer is invalid. If the peer is a trusted proxy, the hops of the Forwarded header, or
+// of X-Forwarded-For without it, are walked from the right, the closest to the server,
+// and the first hop that is not a trusted proxy is the client. If every hop is trusted
+// the leftmost is, and if a hop is not an IP, e.g. "unknown", the last trusted one is.
+// X-Real-Ip is used if neither is set.
+func (c *Resolver) Resolve(r *http.Request) string {
+	peer, ok := parseNode(r.RemoteAddr)
+	if !ok {
+		return ""
+	}
+	if !c.trusts(peer) {
+		return peer.String()
+	}
+
+	var hops []string
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		hops, ok = parseForwarded(values)
+	}
+	if !ok || len(hops) == 0 {
+		hops = nil
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(value, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if ip, ok := parseNode(r.Header.Get("X-Real-Ip")); ok {
+			return ip.String()
+		}
+		return peer.String()
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseNode(hops[i])
+		if !ok {
+			break
+		}
+		client = ip
+		if !c.trusts(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// parseNode returns the IP of a node of a forwarding header or a RemoteAddr: an IP,
+// optionally with a port, and IPv6 addresses in brackets with a port.
+func parseNode(node string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+	ip, err := netip.ParseAddr(node)
+	if err != nil || ip.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+// parseForwarded returns the for parameters of the elements of the RFC 7239 Forwarded
+// header values, in order, and whether they are well formed. Values may be quoted
+// strings with escapes, e.g. for="[2001:db8::1]:4711". An element without a for
+// parameter has an empty node.
+func parseForwarded(values []string) ([]string, bool) {
+	var hops []string
+	for _, value := range values {
+		for value != "" {
+			var node string
+			// the pairs of an element, separated by semicolons
+			for {
+				value = strings.TrimLeft(value, " \t")
+				eq := strings.IndexByte(value, '=')
+				if eq <= 0 {
+					return nil, false
+				}
+				key := strings.ToLower(strings.TrimSpace(value[:eq]))
+				val, rest, ok := forwardedValue(value[eq+1:])
+				if !ok {
+					return nil, false
+				}
+				if key == "for" {
+					node = val
+				}
+				rest = strings.TrimLeft(rest, " \t")
+				if strings.HasPrefix(rest, ";") {
+					value = rest[1:]
+					continue
+				}
+				if rest != "" && !strings.HasPrefix(rest, ",") {
+					return nil, false
+				}
+				value = strings.TrimPrefix(rest, ",")
+				break
+			}
+			hops = append(hops, node)
+			value = strings.TrimLeft(value, " \t")
+		}
+	}
+	return hops, true
+}
+
+// forwardedValue returns the token or quoted string at the start of s, unquoted, and
+// the rest of s.
+func forwardedValue(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, ";, \t")
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], s[end:], end > 0
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", false
+			}
+			i++
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", false
+}
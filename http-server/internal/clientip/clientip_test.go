@@ -0,0 +1,121 @@
+package clientip
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "2001:db8:cafe::/48", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+
+	var tests = []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{name: "untrusted peer", remoteAddr: "203.0.113.9:1234", headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, want: "203.0.113.9"},
+		{name: "trusted peer without headers", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "trusted single IP", remoteAddr: "192.0.2.1:1234", headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, want: "198.51.100.7"},
+		{name: "forwarded", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {"for=198.51.100.7;proto=https;by=10.0.0.1"}}, want: "198.51.100.7"},
+		{name: "forwarded quoted ipv6 with port", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {`For="[2001:db8::17]:4711"`}}, want: "2001:db8::17"},
+		{name: "forwarded ipv4 with port", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {`for="198.51.100.7:4711"`}}, want: "198.51.100.7"},
+		{
+			name:       "forwarded hops walked from the right",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"Forwarded": {"for=203.0.113.50, for=198.51.100.7", `for="[2001:db8:cafe::1]", for=10.0.0.2`}},
+			want:       "198.51.100.7",
+		},
+		{name: "forwarded with quoted comma", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {`for=198.51.100.7;host="a,b", for=10.0.0.2`}}, want: "198.51.100.7"},
+		{name: "forwarded unknown client", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {"for=unknown, for=10.0.0.2"}}, want: "10.0.0.2"},
+		{name: "forwarded obfuscated client", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {"for=_hidden"}}, want: "10.0.0.1"},
+		{
+			name:       "malformed forwarded falls back to x-forwarded-for",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"Forwarded": {`for="198.51.100.7`}, "X-Forwarded-For": {"198.51.100.8"}},
+			want:       "198.51.100.8",
+		},
+		{
+			name:       "x-forwarded-for walked from the right",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.50, 198.51.100.7", "10.0.0.3,10.0.0.2"}},
+			want:       "198.51.100.7",
+		},
+		{name: "x-forwarded-for all trusted", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, want: "10.0.0.3"},
+		{name: "x-real-ip", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Real-Ip": {"198.51.100.7"}}, want: "198.51.100.7"},
+		{name: "invalid x-real-ip", remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Real-Ip": {"nope"}}, want: "10.0.0.1"},
+		{name: "ipv4 mapped peer", remoteAddr: "[::ffff:10.0.0.1]:1234", headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, want: "198.51.100.7"},
+		{name: "invalid peer", remoteAddr: "1234", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			for name, values := range test.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			if got := resolver.Resolve(req); got != test.want {
+				t.Errorf("Resolve() = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseForwarded(t *testing.T) {
+	var tests = []struct {
+		name   string
+		input  []string
+		want   []string
+		wantOK bool
+	}{
+		{name: "single element", input: []string{"for=192.0.2.60;proto=http;by=203.0.113.43"}, want: []string{"192.0.2.60"}, wantOK: true},
+		{name: "elements and values", input: []string{"for=192.0.2.43, for=198.51.100.17", "for=unknown"}, want: []string{"192.0.2.43", "198.51.100.17", "unknown"}, wantOK: true},
+		{name: "quoted escapes", input: []string{`for="[2001:db8::1]:80";host="a\"b"`}, want: []string{"[2001:db8::1]:80"}, wantOK: true},
+		{name: "element without for", input: []string{"proto=https, for=192.0.2.1"}, want: []string{"", "192.0.2.1"}, wantOK: true},
+		{name: "unterminated quote", input: []string{`for="192.0.2.1`}},
+		{name: "missing value", input: []string{"for="}},
+		{name: "missing pair", input: []string{"192.0.2.1"}},
+		{name: "garbage after value", input: []string{"for=192.0.2.1 x"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parseForwarded(test.input)
+			if ok != test.wantOK {
+				t.Fatalf("parseForwarded() ok = %v; want %v", ok, test.wantOK)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("parseForwarded() = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	if _, err := NewResolver([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("NewResolver() error = nil; want an error")
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got := (&Resolver{}).Resolve(req); got != "192.0.2.1" {
+		t.Errorf("Resolve() of the zero Resolver = %q; want the peer 192.0.2.1", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("FromContext() = %q; want empty", got)
+	}
+	if got := FromContext(NewContext(context.Background(), "198.51.100.7")); got != "198.51.100.7" {
+		t.Errorf("FromContext() = %q; want 198.51.100.7", got)
+	}
+}
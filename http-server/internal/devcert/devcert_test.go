@@ -0,0 +1,91 @@
+package devcert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnsureDevCerts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	first, err := Ensure(dir)
+	if err != nil {
+		t.Fatalf("Ensure() = unexpected error: %v", err)
+	}
+	if !first.Created {
+		t.Errorf("Created = false; want true")
+	}
+	fi, err := os.Stat(first.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v; want %v", fi.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	ca, roots := readDevCA(t, first.CAFile)
+	pair, err := tls.LoadX509KeyPair(first.CertFile, first.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("Verify(%s) = unexpected error: %v", host, err)
+		}
+	}
+
+	cached, err := Ensure(dir)
+	if err != nil {
+		t.Fatalf("Ensure() = unexpected error: %v", err)
+	}
+	if cached.Created {
+		t.Errorf("Created = true; want false")
+	}
+	if diff := cmp.Diff(first, Certs{CAFile: cached.CAFile, CertFile: cached.CertFile, KeyFile: cached.KeyFile, Created: true}); diff != "" {
+		t.Errorf("Ensure() = unexpected result (-want +got):\n%s\n", diff)
+	}
+
+	if err := os.Remove(first.CertFile); err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := Ensure(dir)
+	if err != nil {
+		t.Fatalf("Ensure() = unexpected error: %v", err)
+	}
+	if !renewed.Created {
+		t.Errorf("Created = false; want true")
+	}
+	if renewedCA, _ := readDevCA(t, renewed.CAFile); !bytes.Equal(ca.Raw, renewedCA.Raw) {
+		t.Errorf("Ensure() = regenerated CA; want CA to be reused")
+	}
+}
+
+// readDevCA reads the CA certificate in file and returns it with a pool containing it.
+func readDevCA(t *testing.T, file string) (*x509.Certificate, *x509.CertPool) {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		t.Fatalf("no certificates in %s", file)
+	}
+	block, _ := pem.Decode(b)
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, roots
+}
@@ -0,0 +1,88 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "pprof index", path: "/debug/pprof/", wantStatus: http.StatusOK, wantContentType: "text/html; charset=utf-8", wantBody: "goroutine"},
+		{name: "pprof profile", path: "/debug/pprof/allocs?debug=1", wantStatus: http.StatusOK, wantContentType: "text/plain; charset=utf-8", wantBody: "heap profile"},
+		{name: "trace", path: "/debug/trace?duration=10ms", wantStatus: http.StatusOK, wantContentType: "application/octet-stream", wantBody: "go 1."},
+		{name: "trace with invalid duration", path: "/debug/trace?duration=1h", wantStatus: http.StatusBadRequest, wantContentType: "text/plain; charset=utf-8"},
+		{name: "heap", path: "/debug/heap?gc=true", wantStatus: http.StatusOK, wantContentType: "application/octet-stream"},
+		{name: "goroutines", path: "/debug/goroutines", wantStatus: http.StatusOK, wantContentType: "text/plain; charset=utf-8", wantBody: "goroutine "},
+		{name: "runtime", path: "/debug/runtime", wantStatus: http.StatusOK, wantContentType: "application/json", wantBody: `"gomaxprocs"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Handler().ServeHTTP(rec, httptest.NewRequest("GET", test.path, nil))
+
+			if rec.Code != test.wantStatus {
+				t.Errorf("GET %s = %d; want %d", test.path, rec.Code, test.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("Content-Type = %q; want %q", got, test.wantContentType)
+			}
+			if test.wantStatus == http.StatusOK && rec.Body.Len() == 0 {
+				t.Errorf("GET %s = empty body", test.path)
+			}
+			if !strings.Contains(rec.Body.String(), test.wantBody) {
+				t.Errorf("GET %s = missing %q", test.path, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestReadRuntimeInfo(t *testing.T) {
+	t.Setenv("GODEBUG", "http2client=0, madvdontneed=1")
+
+	got := readRuntimeInfo()
+	if got.GOMAXPROCS != runtime.GOMAXPROCS(0) {
+		t.Errorf("GOMAXPROCS = %d; want %d", got.GOMAXPROCS, runtime.GOMAXPROCS(0))
+	}
+	want := map[string]string{"http2client": "0", "madvdontneed": "1"}
+	if diff := cmp.Diff(want, got.GODEBUG); diff != "" {
+		t.Errorf("GODEBUG mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := json.Marshal(got); err != nil {
+		t.Errorf("json.Marshal() = %v", err)
+	}
+}
+
+func TestLoopbackAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1:9090", want: true},
+		{addr: "localhost:9090", want: true},
+		{addr: "[::1]:9090", want: true},
+		{addr: "[::ffff:127.0.0.1]:9090", want: true},
+		{addr: ":9090", want: false},
+		{addr: "0.0.0.0:9090", want: false},
+		{addr: "192.0.2.1:9090", want: false},
+		{addr: "127.0.0.1", want: false},
+	}
+
+	for _, test := range tests {
+		if got := LoopbackAddress(test.addr); got != test.want {
+			t.Errorf("LoopbackAddress(%q) = %v; want %v", test.addr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Package listener listens on TCP, unix domain sockets and sockets inherited through
+// systemd socket activation, and hands off listeners to a new process on restart.
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Networks of a Config.
+const (
+	NetworkTCP     = "tcp"
+	NetworkUnix    = "unix"
+	NetworkSystemd = "systemd"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket activation, and
+// of the listeners handed off on restart.
+const listenFdsStart = 3
+
+// Config describes where to listen.
+type Config struct {
+	// Network is tcp, unix or systemd.
+	Network string
+	// Address is host:port for tcp and the path of the socket for unix. For systemd
+	// it is the name of the socket (FileDescriptorName=), or empty for all
+	// inherited sockets not claimed by another Config.
+	Address string
+	// Mode is the file mode of a unix socket, e.g. 0660. Defaults to the umask.
+	Mode os.FileMode
+}
+
+// String returns the network and address of the Config.
+func (c Config) String() string {
+	return c.Network + ":" + c.Address
+}
+
+// ListenAll listens on all configs in order. If one fails, the listeners
+// already created are closed.
+func ListenAll(configs []Config) ([]net.Listener, error) {
+	listeners, _, err := ListenNamed(configs)
+	return listeners, err
+}
+
+// ListenNamed listens on all configs in order like ListenAll, and also returns
+// the config each listener was created for as its name, to hand it off with Restart.
+func ListenNamed(configs []Config) ([]net.Listener, []string, error) {
+	var listeners []net.Listener
+	var names []string
+	for _, c := range configs {
+		lns, err := Listen(c)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, nil, err
+		}
+		listeners = append(listeners, lns...)
+		for range lns {
+			names = append(names, c.String())
+		}
+	}
+	return listeners, names, nil
+}
+
+// Listen returns the listeners for c. It returns one listener for tcp and unix,
+// and the matching inherited listeners for systemd. Listeners handed off by a
+// previous process on restart are reused.
+func Listen(c Config) ([]net.Listener, error) {
+	lns, err := handoffListeners.take(c.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(lns) > 0 {
+		return lns, nil
+	}
+
+	switch c.Network {
+	case NetworkTCP, "":
+		ln, err := net.Listen("tcp", c.Address)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{ln}, nil
+	case NetworkUnix:
+		ln, err := listenUnix(c.Address, c.Mode)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{ln}, nil
+	case NetworkSystemd:
+		return systemdListeners.claim(c.Address)
+	default:
+		return nil, fmt.Errorf("listen %s: unsupported network %q", c, c.Network)
+	}
+}
+
+// listenUnix listens on the unix socket at path and sets its file mode. A stale
+// socket left by a previous process is removed first.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen unix %s: %w", path, syscall.EADDRINUSE)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+// systemdListeners holds the listeners inherited from systemd by the process.
+var systemdListeners = &inheritedListeners{
+	load: func() ([]net.Listener, []string, error) {
+		defer func() {
+			os.Unsetenv("LISTEN_PID")
+			os.Unsetenv("LISTEN_FDS")
+			os.Unsetenv("LISTEN_FDNAMES")
+		}()
+		return inheritListeners(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), listenFdsStart)
+	},
+}
+
+// handoffListeners holds the listeners handed off by the previous process on restart.
+var handoffListeners = &inheritedListeners{
+	load: func() ([]net.Listener, []string, error) {
+		defer os.Unsetenv(envHandoffListeners)
+		return inheritHandoff(os.Getenv(envHandoffListeners), listenFdsStart)
+	},
+}
+
+// inheritedListeners are listeners passed to the process, by systemd socket
+// activation or by a previous process on restart. They are loaded once, and
+// each is claimed by at most one Config.
+type inheritedListeners struct {
+	load      func() ([]net.Listener, []string, error)
+	once      sync.Once
+	mu        sync.Mutex
+	listeners []net.Listener
+	names     []string
+	err       error
+}
+
+// claim returns the unclaimed inherited listeners named name, or all unclaimed
+// listeners if name is empty. It fails if there are none.
+func (l *inheritedListeners) claim(name string) ([]net.Listener, error) {
+	claimed, err := l.take(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return nil, fmt.Errorf("listen systemd:%s: no inherited socket", name)
+	}
+	return claimed, nil
+}
+
+// take returns the unclaimed inherited listeners named name, or all unclaimed
+// listeners if name is empty, and marks them as claimed.
+func (l *inheritedListeners) take(name string) ([]net.Listener, error) {
+	l.once.Do(func() {
+		if l.load != nil {
+			l.listeners, l.names, l.err = l.load()
+		}
+	})
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var claimed []net.Listener
+	for i, ln := range l.listeners {
+		if ln == nil || (name != "" && l.names[i] != name) {
+			continue
+		}
+		claimed = append(claimed, ln)
+		l.listeners[i] = nil
+	}
+	return claimed, nil
+}
+
+// inheritListeners returns listeners for the file descriptors passed with the
+// systemd socket activation protocol, starting at the file descriptor start. It
+// returns no listeners if they were passed to another process.
+func inheritListeners(pid, fds, fdNames string, start int) ([]net.Listener, []string, error) {
+	if pid == "" || fds == "" {
+		return nil, nil, nil
+	}
+	if p, err := strconv.Atoi(pid); err != nil || p != os.Getpid() {
+		return nil, nil, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, nil, fmt.Errorf("systemd: invalid LISTEN_FDS %q", fds)
+	}
+	names := strings.Split(fdNames, ":")
+
+	var listeners []net.Listener
+	var listenerNames []string
+	var errs []error
+	for i := 0; i < n; i++ {
+		fd := start + i
+		syscall.CloseOnExec(fd)
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("systemd: socket %s: %w", name, err))
+			continue
+		}
+		listeners = append(listeners, ln)
+		listenerNames = append(listenerNames, name)
+	}
+	if err := errors.Join(errs...); err != nil {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+		return nil, nil, err
+	}
+	return listeners, listenerNames, nil
+}
@@ -0,0 +1,111 @@
+package logctx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandler(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			ctx  func() context.Context
+			args []any
+		}
+		want map[string]any
+	}{
+		{
+			name: "no context values",
+			input: struct {
+				ctx  func() context.Context
+				args []any
+			}{
+				ctx:  context.Background,
+				args: []any{"status", "ok"},
+			},
+			want: map[string]any{"msg": "message", "status": "ok"},
+		},
+		{
+			name: "request ID, trace IDs and attributes",
+			input: struct {
+				ctx  func() context.Context
+				args []any
+			}{
+				ctx: func() context.Context {
+					ctx := WithRequestID(context.Background(), "abc-123")
+					ctx = WithTraceIDs(ctx, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+					return WithAttrs(ctx, slog.String("job", "cleanup"))
+				},
+			},
+			want: map[string]any{"msg": "message", "requestId": "abc-123", "traceId": "4bf92f3577b34da6a3ce929d0e0e4736", "spanId": "00f067aa0ba902b7", "job": "cleanup", "userId": "42"},
+		},
+		{
+			name: "attributes of the record take precedence",
+			input: struct {
+				ctx  func() context.Context
+				args []any
+			}{
+				ctx: func() context.Context {
+					return WithTraceIDs(WithRequestID(context.Background(), "abc-123"), "4bf92f3577b34da6a3ce929d0e0e4736", "")
+				},
+				args: []any{"requestId", "def-456"},
+			},
+			want: map[string]any{"msg": "message", "requestId": "def-456", "traceId": "4bf92f3577b34da6a3ce929d0e0e4736", "userId": "42"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			userID := func(ctx context.Context) []slog.Attr {
+				if RequestID(ctx) == "" {
+					return nil
+				}
+				return []slog.Attr{slog.String("userId", "42")}
+			}
+			handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey || a.Key == slog.LevelKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			})
+			log := slog.New(NewHandler(handler, DefaultKeys, userID))
+			log.InfoContext(test.input.ctx(), "message", test.input.args...)
+
+			got := map[string]any{}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Handler.Handle() = invalid JSON: %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Handler.Handle() = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestHandler_Keys(t *testing.T) {
+	var buf bytes.Buffer
+	keys := Keys{RequestID: "id", TraceID: "trace-id", SpanID: "span-id"}
+	log := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil), keys))
+	ctx := WithTraceIDs(WithRequestID(context.Background(), "abc-123"), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	log.With("job", "cleanup").WithGroup("details").InfoContext(ctx, "message")
+
+	got := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Handler.Handle() = invalid JSON: %v", err)
+	}
+	want := map[string]any{"job": "cleanup", "details": map[string]any{"id": "abc-123", "trace-id": "4bf92f3577b34da6a3ce929d0e0e4736", "span-id": "00f067aa0ba902b7"}}
+	delete(got, slog.TimeKey)
+	delete(got, slog.LevelKey)
+	delete(got, slog.MessageKey)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Handler.Handle() = unexpected result (-want +got):\n%s\n", diff)
+	}
+}
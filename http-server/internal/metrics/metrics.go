@@ -0,0 +1,112 @@
+// Package metrics records the RED metrics (rate, errors and duration) of HTTP requests in
+// a Prometheus registry, with the Go runtime and process collectors.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Labels of the request metrics.
+const (
+	metricsLabelRoute  = "route"
+	metricsLabelMethod = "method"
+	metricsLabelStatus = "status"
+)
+
+// Label values for requests that did not match a route and for nonstandard
+// methods, so that clients cannot create new series.
+const (
+	metricsUnmatchedRoute = "unmatched"
+	metricsOtherMethod    = "OTHER"
+)
+
+// Metrics holds a Prometheus registry with the Go runtime and process collectors,
+// and the RED metrics (rate, errors and duration) of the requests served.
+type Metrics struct {
+	// Registry is served by Handler, custom metrics can be registered with it.
+	Registry *prometheus.Registry
+
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	inFlight     prometheus.Gauge
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+}
+
+// New returns Metrics with a new registry.
+func New() *Metrics {
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 8)
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests served, by route, method and status.",
+		}, []string{metricsLabelRoute, metricsLabelMethod, metricsLabelStatus}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests, by route, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{metricsLabelRoute, metricsLabelMethod, metricsLabelStatus}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests being served.",
+		}),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "Size of HTTP request bodies, by route and method.",
+			Buckets: sizeBuckets,
+		}, []string{metricsLabelRoute, metricsLabelMethod}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies, by route, method and status.",
+			Buckets: sizeBuckets,
+		}, []string{metricsLabelRoute, metricsLabelMethod, metricsLabelStatus}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.requestSize,
+		m.responseSize,
+	)
+	return m
+}
+
+// Handler returns a handler serving the metrics of the registry in the Prometheus
+// text exposition format. Compression is left to the middleware of the server.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry, DisableCompression: true})
+}
+
+// Start records a request in flight, the returned function records it as finished.
+func (m *Metrics) Start() func() {
+	m.inFlight.Inc()
+	return m.inFlight.Dec
+}
+
+// Observe records a finished request. route is the pattern the request matched,
+// or empty if it did not match any.
+func (m *Metrics) Observe(route, method string, status int, duration time.Duration, requestSize, responseSize int) {
+	if route == "" {
+		route = metricsUnmatchedRoute
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+	default:
+		method = metricsOtherMethod
+	}
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(route, method, code).Inc()
+	m.duration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+	m.requestSize.WithLabelValues(route, method).Observe(float64(requestSize))
+	m.responseSize.WithLabelValues(route, method, code).Observe(float64(responseSize))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetrics_Observe(t *testing.T) {
+	m := New()
+	m.Observe("/items/{id}", http.MethodPost, http.StatusCreated, 10*time.Millisecond, 5, 2)
+	m.Observe("", http.MethodGet, http.StatusNotFound, time.Millisecond, 0, 19)
+	m.Observe("", "PURGE", http.StatusMethodNotAllowed, time.Millisecond, 0, 19)
+
+	want := []string{
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_requests_total{method="OTHER",route="unmatched",status="405"} 1`,
+		`http_requests_total{method="POST",route="/items/{id}",status="201"} 1`,
+	}
+	if diff := cmp.Diff(want, scrape(t, m, "http_requests_total{")); diff != "" {
+		t.Errorf("Observe() = unexpected metrics (-want +got):\n%s\n", diff)
+	}
+}
+
+func TestMetrics_Start(t *testing.T) {
+	m := New()
+	done := m.Start()
+	if diff := cmp.Diff([]string{"http_requests_in_flight 1"}, scrape(t, m, "http_requests_in_flight ")); diff != "" {
+		t.Errorf("Start() = unexpected metrics (-want +got):\n%s\n", diff)
+	}
+	done()
+	if diff := cmp.Diff([]string{"http_requests_in_flight 0"}, scrape(t, m, "http_requests_in_flight ")); diff != "" {
+		t.Errorf("Start()() = unexpected metrics (-want +got):\n%s\n", diff)
+	}
+}
+
+// scrape returns the lines served by the handler of m that start with prefix.
+func scrape(t *testing.T, m *Metrics, prefix string) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	var lines []string
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), prefix) {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	return lines
+}
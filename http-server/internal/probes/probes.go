@@ -0,0 +1,173 @@
+// Package probes serves Kubernetes style liveness, readiness and startup probes, for
+// the http-server template.
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for probe configuration.
+const (
+	defaultProbeTimeout = 2 * time.Second
+)
+
+// probeCheck is a named check run by a probe.
+type probeCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// Probes serves Kubernetes style liveness, readiness and startup probes.
+// The handlers are plain http.Handlers so they can be mounted on any router.
+type Probes struct {
+	mu           sync.RWMutex
+	liveness     []probeCheck
+	readiness    []probeCheck
+	startup      []probeCheck
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+	timeout      time.Duration
+}
+
+// New returns a new Probes. Readiness fails until SetStarted is called
+// and after SetShuttingDown is called.
+func New() *Probes {
+	p := &Probes{
+		timeout: defaultProbeTimeout,
+	}
+	p.liveness = []probeCheck{{name: "ping", check: func(ctx context.Context) error { return nil }}}
+	p.readiness = []probeCheck{
+		{name: "started", check: p.checkStarted},
+		{name: "shutdown", check: p.checkShutdown},
+	}
+	p.startup = []probeCheck{{name: "started", check: p.checkStarted}}
+	return p
+}
+
+// AddLivenessCheck adds a check to the liveness probe.
+func (p *Probes) AddLivenessCheck(name string, check func(ctx context.Context) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.liveness = append(p.liveness, probeCheck{name: name, check: check})
+}
+
+// AddReadinessCheck adds a check to the readiness probe, typically for a required dependency.
+func (p *Probes) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readiness = append(p.readiness, probeCheck{name: name, check: check})
+}
+
+// AddStartupCheck adds a check to the startup probe.
+func (p *Probes) AddStartupCheck(name string, check func(ctx context.Context) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.startup = append(p.startup, probeCheck{name: name, check: check})
+}
+
+// SetStarted marks startup as complete.
+func (p *Probes) SetStarted() {
+	p.started.Store(true)
+}
+
+// SetShuttingDown marks the start of a graceful shutdown, which fails the readiness probe.
+func (p *Probes) SetShuttingDown() {
+	p.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether SetShuttingDown has been called.
+func (p *Probes) ShuttingDown() bool {
+	return p.shuttingDown.Load()
+}
+
+// LivezHandler returns the handler for the liveness probe.
+func (p *Probes) LivezHandler() http.Handler {
+	return p.handler("livez", func() []probeCheck { return p.checks(&p.liveness) })
+}
+
+// ReadyzHandler returns the handler for the readiness probe.
+func (p *Probes) ReadyzHandler() http.Handler {
+	return p.handler("readyz", func() []probeCheck { return p.checks(&p.readiness) })
+}
+
+// StartupzHandler returns the handler for the startup probe.
+func (p *Probes) StartupzHandler() http.Handler {
+	return p.handler("startupz", func() []probeCheck { return p.checks(&p.startup) })
+}
+
+// checks returns a copy of the checks in list.
+func (p *Probes) checks(list *[]probeCheck) []probeCheck {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	checks := make([]probeCheck, len(*list))
+	copy(checks, *list)
+	return checks
+}
+
+// handler runs the checks returned by checks and writes the result. The individual
+// results are listed if the request has the query parameter verbose or if any check failed.
+func (p *Probes) handler(name string, checks func() []probeCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+		defer cancel()
+
+		list := checks()
+		errs := make([]error, len(list))
+		var wg sync.WaitGroup
+		for i, c := range list {
+			wg.Add(1)
+			go func(i int, c probeCheck) {
+				defer wg.Done()
+				errs[i] = c.check(ctx)
+			}(i, c)
+		}
+		wg.Wait()
+
+		failed := false
+		var b strings.Builder
+		for i, c := range list {
+			if errs[i] != nil {
+				failed = true
+				fmt.Fprintf(&b, "[-]%s failed: %v\n", c.name, errs[i])
+				continue
+			}
+			fmt.Fprintf(&b, "[+]%s ok\n", c.name)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "%s%s check failed\n", b.String(), name)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Has("verbose") {
+			fmt.Fprintf(w, "%s%s check passed\n", b.String(), name)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+}
+
+// checkStarted fails until startup is complete.
+func (p *Probes) checkStarted(ctx context.Context) error {
+	if !p.started.Load() {
+		return fmt.Errorf("not started")
+	}
+	return nil
+}
+
+// checkShutdown fails once a graceful shutdown has begun.
+func (p *Probes) checkShutdown(ctx context.Context) error {
+	if p.shuttingDown.Load() {
+		return fmt.Errorf("shutting down")
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+// Package ratelimit limits requests with token buckets, kept in memory or in a Redis-like
+// store shared by the instances of a service.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for rate limit configuration.
+const (
+	numShards     = 64
+	sweepInterval = time.Minute
+)
+
+// Policy allows Limit requests per Period, refilled continuously like a token
+// bucket, and bursts of up to Burst requests.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+	// Burst is the capacity of the bucket, defaults to Limit.
+	Burst int
+}
+
+// ParsePolicy parses a policy of the form limit/period, e.g. 100/1m or 10/s.
+func ParsePolicy(s string) (Policy, error) {
+	limit, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %q: expected limit/period", s)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q: limit must be a positive integer", s)
+	}
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q: period must be a positive duration", s)
+	}
+	return Policy{Limit: n, Period: d}, nil
+}
+
+// burst returns the capacity of the bucket of the policy.
+func (p Policy) burst() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+	return float64(p.Limit)
+}
+
+// rate returns the tokens added to the bucket of the policy per second.
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// String returns the policy in the format of the RateLimit-Policy header, e.g. 100;w=60.
+func (p Policy) String() string {
+	s := strconv.Itoa(p.Limit) + ";w=" + strconv.Itoa(int(math.Ceil(p.Period.Seconds())))
+	if p.Burst > 0 && p.Burst != p.Limit {
+		s += ";burst=" + strconv.Itoa(p.Burst)
+	}
+	return s
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Policy    Policy
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero if it is allowed.
+	RetryAfter time.Duration
+}
+
+// newResult returns the result of a request for policy that left tokens in the bucket.
+func newResult(policy Policy, tokens float64, allowed bool) Result {
+	rate := policy.rate()
+	res := Result{
+		Policy:    policy,
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((policy.burst() - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return res
+}
+
+// WriteHeaders sets the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers of the result on h, and Retry-After if the request is denied.
+// Durations are in seconds, rounded up.
+func (r Result) WriteHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.Itoa(r.Policy.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(r.Reset)))
+	h.Set("RateLimit-Policy", r.Policy.String())
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(r.RetryAfter), 1)))
+	}
+}
+
+// ceilSeconds returns d in seconds, rounded up.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Store holds the token buckets of a Limiter by key. Take must be atomic for
+// a key, a store shared by the instances of a service, e.g. RedisStore, makes
+// them share their limits.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error)
+}
+
+// tokenBucket is the state of a bucket, the tokens it held when it was last updated.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills the bucket with rate tokens per second up to burst, and takes a token
+// if there is one. A new bucket is full.
+func (b *tokenBucket) take(burst, rate float64, now time.Time) bool {
+	if b.updated.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed.Seconds()*rate)
+	}
+	if now.After(b.updated) {
+		b.updated = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// MemoryStore is a Store in memory, sharded by key to reduce lock
+// contention. Buckets that have been refilled are removed periodically.
+type MemoryStore struct {
+	shards [numShards]bucketShard
+}
+
+// bucketShard holds the buckets of the keys of a shard.
+type bucketShard struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	swept   time.Time
+}
+
+// memoryBucket is a tokenBucket and the time it is full again.
+type memoryBucket struct {
+	tokenBucket
+	full time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	for i := range s.shards {
+		s.shards[i].buckets = make(map[string]*memoryBucket)
+	}
+	return s
+}
+
+// Take takes a token from the bucket of key.
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%numShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.Sub(shard.swept) >= sweepInterval {
+		shard.sweep(now)
+	}
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &memoryBucket{}
+		shard.buckets[key] = b
+	}
+	allowed := b.take(policy.burst(), policy.rate(), now)
+	res := newResult(policy, b.tokens, allowed)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// len returns the number of buckets in the store.
+func (s *MemoryStore) len() int {
+	var n int
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += len(s.shards[i].buckets)
+		s.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+// sweep removes the buckets that are full at now, they are the same as new buckets.
+func (s *bucketShard) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	s.swept = now
+}
+
+// RedisEvaler runs a Lua script atomically, like the EVAL command of Redis. It is the
+// subset of a Redis client used by RedisStore, e.g. an adapter of the Eval
+// method of a client library, or a local fake in tests.
+type RedisEvaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// takeScript takes a token from the bucket in the hash KEYS[1], with the capacity
+// ARGV[1], the refill rate ARGV[2] in tokens per millisecond, at the time ARGV[3] in unix
+// milliseconds. It returns whether the token was taken, and the tokens left as a string,
+// as Redis truncates numbers to integers. The key expires once the bucket is full.
+const takeScript = `
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+if now > updated then
+  tokens = math.min(burst, tokens + (now - updated) * rate)
+  updated = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", updated)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1)
+return {allowed, tostring(tokens)}
+`
+
+// RedisStore is a Store on a Redis-like backend, shared by the
+// instances of a service. The buckets are hashes with the prefix of the store.
+type RedisStore struct {
+	client RedisEvaler
+	prefix string
+}
+
+// NewRedisStore returns a RedisStore on client, with keys prefixed by prefix.
+func NewRedisStore(client RedisEvaler, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take takes a token from the bucket of key with takeScript.
+func (s *RedisStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	reply, err := s.client.Eval(ctx, takeScript, []string{s.prefix + key},
+		policy.burst(), policy.rate()/1000, now.UnixMilli())
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit: %w", err)
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("rate limit: unexpected reply %v", reply)
+	}
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return Result{}, fmt.Errorf("rate limit: unexpected reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit: unexpected reply %v", reply)
+	}
+	return newResult(policy, tokens, allowed == 1), nil
+}
+
+// Limiter limits requests by key with the token buckets of a Store.
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter returns a Limiter on store, or on a MemoryStore if it is nil.
+func NewLimiter(store Store) *Limiter {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Take takes a token from the bucket of key for policy. If the store fails the request
+// is allowed, with the error, so that the limiter does not take the service down with it.
+func (l *Limiter) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	res, err := l.store.Take(ctx, key, policy, l.now())
+	if err != nil {
+		return Result{Policy: policy, Allowed: true, Remaining: int(policy.burst())}, err
+	}
+	return res, nil
+}
+
+// HashKey returns a hash of a secret used as a rate limit key, e.g. an API key,
+// so that it is not kept in the store.
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:16])
+}
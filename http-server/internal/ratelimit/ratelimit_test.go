@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeRedis is a RedisEvaler that runs takeScript in memory.
+type fakeRedis struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	err     error
+}
+
+func (f *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if script != takeScript {
+		return nil, errors.New("unknown script")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buckets == nil {
+		f.buckets = make(map[string]*tokenBucket)
+	}
+	b, ok := f.buckets[keys[0]]
+	if !ok {
+		b = &tokenBucket{}
+		f.buckets[keys[0]] = b
+	}
+	var allowed int64
+	if b.take(args[0].(float64), args[1].(float64)*1000, time.UnixMilli(args[2].(int64))) {
+		allowed = 1
+	}
+	return []any{allowed, strconv.FormatFloat(b.tokens, 'f', -1, 64)}, nil
+}
+
+func TestParsePolicy(t *testing.T) {
+	var tests = []struct {
+		name    string
+		input   string
+		want    Policy
+		wantErr string
+	}{
+		{name: "limit per period", input: "100/1m", want: Policy{Limit: 100, Period: time.Minute}},
+		{name: "limit per unit", input: "10/s", want: Policy{Limit: 10, Period: time.Second}},
+		{name: "without period", input: "100", wantErr: `rate limit "100": expected limit/period`},
+		{name: "invalid limit", input: "0/s", wantErr: `rate limit "0/s": limit must be a positive integer`},
+		{name: "invalid period", input: "10/week", wantErr: `rate limit "10/week": period must be a positive duration`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParsePolicy(test.input)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("ParsePolicy() error = %v; want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePolicy() error = %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ParsePolicy() = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  NewRedisStore(&fakeRedis{}, "ratelimit:"),
+	}
+	policy := Policy{Limit: 2, Period: time.Second, Burst: 3}
+	start := time.Unix(1700000000, 0)
+
+	type result struct {
+		Allowed    bool
+		Remaining  int
+		RetryAfter time.Duration
+	}
+	steps := []struct {
+		at   time.Duration
+		want result
+	}{
+		{at: 0, want: result{Allowed: true, Remaining: 2}},
+		{at: 0, want: result{Allowed: true, Remaining: 1}},
+		{at: 0, want: result{Allowed: true, Remaining: 0}},
+		{at: 0, want: result{Allowed: false, Remaining: 0, RetryAfter: 500 * time.Millisecond}},
+		// refilled at 2 tokens per second
+		{at: 500 * time.Millisecond, want: result{Allowed: true, Remaining: 0}},
+		{at: 5 * time.Second, want: result{Allowed: true, Remaining: 2}},
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i, step := range steps {
+				res, err := store.Take(context.Background(), "client", policy, start.Add(step.at))
+				if err != nil {
+					t.Fatalf("Take() error = %v", err)
+				}
+				got := result{Allowed: res.Allowed, Remaining: res.Remaining, RetryAfter: res.RetryAfter}
+				if diff := cmp.Diff(step.want, got); diff != "" {
+					t.Errorf("Take() #%d = unexpected result (-want +got):\n%s\n", i, diff)
+				}
+			}
+			res, _ := store.Take(context.Background(), "other", policy, start)
+			if !res.Allowed {
+				t.Errorf("Take() of another key = denied; want allowed")
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Limit: 10, Period: time.Second}
+	start := time.Unix(1700000000, 0)
+	for _, key := range []string{"a", "b", "c"} {
+		store.Take(context.Background(), key, policy, start)
+	}
+	if got := store.len(); got != 3 {
+		t.Fatalf("len() = %d; want 3", got)
+	}
+
+	// the buckets are full after 100ms, and removed by the sweep of their shard
+	later := start.Add(sweepInterval)
+	for i := range store.shards {
+		store.shards[i].mu.Lock()
+		store.shards[i].sweep(later)
+		store.shards[i].mu.Unlock()
+	}
+	if got := store.len(); got != 0 {
+		t.Errorf("len() after sweep = %d; want 0", got)
+	}
+}
+
+func TestLimiter_StoreError(t *testing.T) {
+	limiter := NewLimiter(NewRedisStore(&fakeRedis{err: errors.New("connection refused")}, ""))
+	res, err := limiter.Take(context.Background(), "client", Policy{Limit: 5, Period: time.Second})
+	if err == nil || err.Error() != "rate limit: connection refused" {
+		t.Errorf("Take() error = %v; want rate limit: connection refused", err)
+	}
+	if !res.Allowed {
+		t.Errorf("Take() = denied; want allowed when the store fails")
+	}
+}
+
+func TestResult_WriteHeaders(t *testing.T) {
+	policy := Policy{Limit: 100, Period: time.Minute, Burst: 20}
+	var tests = []struct {
+		name  string
+		input Result
+		want  http.Header
+	}{
+		{
+			name:  "allowed",
+			input: Result{Policy: policy, Allowed: true, Remaining: 19, Reset: 600 * time.Millisecond},
+			want: http.Header{
+				"Ratelimit-Limit":     {"100"},
+				"Ratelimit-Remaining": {"19"},
+				"Ratelimit-Reset":     {"1"},
+				"Ratelimit-Policy":    {"100;w=60;burst=20"},
+			},
+		},
+		{
+			name:  "denied",
+			input: Result{Policy: policy, Remaining: 0, Reset: 12 * time.Second, RetryAfter: 100 * time.Millisecond},
+			want: http.Header{
+				"Ratelimit-Limit":     {"100"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"12"},
+				"Ratelimit-Policy":    {"100;w=60;burst=20"},
+				"Retry-After":         {"1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := http.Header{}
+			test.input.WriteHeaders(got)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("WriteHeaders() = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
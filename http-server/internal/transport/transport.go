@@ -0,0 +1,18 @@
+// Package transport defines the additional protocols a server can serve its handler over.
+package transport
+
+import (
+	"context"
+	"net/http"
+)
+
+// Transport serves the handler of a server over an additional protocol,
+// alongside its http.Server, e.g. HTTP/3 over QUIC with a QUIC library.
+//
+// Serve must block until the transport is shut down and then return
+// http.ErrServerClosed. Shutdown must stop accepting new connections and
+// wait for active requests to finish, or for ctx to be done.
+type Transport interface {
+	Serve(handler http.Handler) error
+	Shutdown(ctx context.Context) error
+}
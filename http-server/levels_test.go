@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_LevelRoutes(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			token  string
+			header string
+		}
+		want int
+	}{
+		{
+			name: "without admin token",
+			input: struct {
+				token  string
+				header string
+			}{header: "Bearer secret"},
+			want: http.StatusNotFound,
+		},
+		{
+			name: "without authorization",
+			input: struct {
+				token  string
+				header string
+			}{token: "secret"},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "wrong token",
+			input: struct {
+				token  string
+				header string
+			}{token: "secret", header: "Bearer guess"},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "admin token",
+			input: struct {
+				token  string
+				header string
+			}{token: "secret", header: "Bearer secret"},
+			want: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewServer(WithOptions(Options{
+				Router:     http.NewServeMux(),
+				Log:        &mockLogger{logs: &[]string{}},
+				AdminToken: test.input.token,
+			}))
+			s.routes()
+			req := httptest.NewRequest("PUT", "/loglevel", strings.NewReader(`{"debugFor":"1m"}`))
+			if test.input.header != "" {
+				req.Header.Set("Authorization", test.input.header)
+			}
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, req)
+
+			if rec.Code != test.want {
+				t.Errorf("PUT /loglevel = %d; want %d", rec.Code, test.want)
+			}
+			wantDebug := test.want == http.StatusOK
+			if got := s.levels.Level().Level() == slog.LevelDebug; got != wantDebug {
+				t.Errorf("PUT /loglevel = debug %t; want %t", got, wantDebug)
+			}
+			s.levels.DebugFor(0)
+		})
+	}
+}
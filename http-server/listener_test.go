@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/listener"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServer_Start_Listeners(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "server.sock")
+	adminAddr := "127.0.0.1:" + strconv.Itoa(freeTestPort(t))
+	tcpAddr := "127.0.0.1:" + strconv.Itoa(freeTestPort(t))
+
+	logs := []string{}
+	srv := NewServer(WithOptions(Options{
+		Router: http.NewServeMux(),
+		Log: &mockLogger{
+			logs: &logs,
+		},
+		Listeners: []listener.Config{
+			{Network: listener.NetworkTCP, Address: tcpAddr},
+			{Network: listener.NetworkUnix, Address: socket, Mode: 0o600},
+		},
+		AdminListener: &listener.Config{Network: listener.NetworkTCP, Address: adminAddr},
+	}))
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	results := make(chan map[string]int, 1)
+	go func() {
+		got := map[string]int{}
+		get := func(name string, client *http.Client, url string) {
+			for i := 0; i < 100; i++ {
+				resp, err := client.Get(url)
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					got[name] = resp.StatusCode
+					if resp.StatusCode == http.StatusOK {
+						return
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		get("tcp", http.DefaultClient, "http://"+tcpAddr+"/livez")
+		get("unix", unixClient, "http://unix/livez")
+		get("admin", http.DefaultClient, "http://"+adminAddr+"/readyz")
+		results <- got
+		time.Sleep(100 * time.Millisecond)
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	}()
+
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start() = unexpected error: %v", err)
+	}
+
+	want := map[string]int{"tcp": http.StatusOK, "unix": http.StatusOK, "admin": http.StatusOK}
+	if diff := cmp.Diff(want, <-results); diff != "" {
+		t.Errorf("Start() = unexpected responses (-want +got):\n%s\n", diff)
+	}
+	wantLog := []string{"Server started.", "address", tcpAddr + ",unix:" + socket, "adminAddress", adminAddr}
+	start := slices.Index(logs, "Server started.")
+	if start < 0 || start+len(wantLog) > len(logs) {
+		t.Fatalf("Start() = missing start log in %v", logs)
+	}
+	if diff := cmp.Diff(wantLog, logs[start:start+len(wantLog)]); diff != "" {
+		t.Errorf("Start() = unexpected logs (-want +got):\n%s\n", diff)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket should be removed on shutdown, got %v", err)
+	}
+}
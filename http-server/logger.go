@@ -1,17 +1,35 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
+
+	"github.com/Zate/go-templates/http-server/internal/levels"
+	"github.com/Zate/go-templates/http-server/internal/logctx"
 )
 
-// logger is the interface that wraps around methods Info and Error.
+// logger is the interface that wraps around methods Debug, Info, Warn and Error,
+// and their variants that log with the request ID and trace ID stored in a context.
 type logger interface {
+	Debug(msg string, args ...any)
 	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
 	Error(msg string, args ...any)
+	DebugContext(ctx context.Context, msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
 }
 
-// NewDefaultLogger creates a new default logger.
+// NewDefaultLogger creates a new default logger, that adds the values of the
+// context of a record with a logctx.Handler.
 func NewDefaultLogger() logger {
-	return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	return slog.New(logctx.NewHandler(slog.NewJSONHandler(os.Stderr, nil), logctx.DefaultKeys))
+}
+
+// NewLogger creates a new default logger that logs at the default level of lc,
+// which can be changed at runtime.
+func NewLogger(lc *levels.Controller) logger {
+	return slog.New(logctx.NewHandler(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: lc.Level()}), logctx.DefaultKeys))
 }
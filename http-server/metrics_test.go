@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zate/go-templates/http-server/internal/listener"
+	"github.com/Zate/go-templates/http-server/internal/metrics"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			method string
+			path   string
+			body   string
+		}
+		want []string
+	}{
+		{
+			name: "route of matched pattern",
+			input: struct {
+				method string
+				path   string
+				body   string
+			}{method: "POST", path: "/items/42", body: "hello"},
+			want: []string{
+				`http_request_size_bytes_sum{method="POST",route="/items/{id}"} 5`,
+				`http_requests_total{method="POST",route="/items/{id}",status="201"} 1`,
+				`http_response_size_bytes_sum{method="POST",route="/items/{id}",status="201"} 2`,
+			},
+		},
+		{
+			name: "unmatched route",
+			input: struct {
+				method string
+				path   string
+				body   string
+			}{method: "GET", path: "/unknown/42"},
+			want: []string{
+				`http_request_size_bytes_sum{method="GET",route="unmatched"} 0`,
+				`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+				`http_response_size_bytes_sum{method="GET",route="unmatched",status="404"} 19`,
+			},
+		},
+		{
+			name: "nonstandard method",
+			input: struct {
+				method string
+				path   string
+				body   string
+			}{method: "PURGE", path: "/items/42"},
+			want: []string{
+				`http_request_size_bytes_sum{method="OTHER",route="unmatched"} 0`,
+				`http_requests_total{method="OTHER",route="unmatched",status="405"} 1`,
+				`http_response_size_bytes_sum{method="OTHER",route="unmatched",status="405"} 19`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := metrics.New()
+			router := http.NewServeMux()
+			router.HandleFunc("POST /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("ok"))
+			})
+			handler := metricsMiddleware(m, router, router)
+
+			req := httptest.NewRequest(test.input.method, test.input.path, strings.NewReader(test.input.body))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			got := scrapeMetrics(t, m, "http_requests_total{", "http_request_size_bytes_sum{", "http_response_size_bytes_sum{")
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("metricsMiddleware() = unexpected metrics (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	t.Run("served on the router", func(t *testing.T) {
+		s := NewServer(WithOptions(Options{
+			Router: http.NewServeMux(),
+			Log:    &mockLogger{logs: &[]string{}},
+		}))
+		s.routes()
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /metrics = %d; want %d", rec.Code, http.StatusOK)
+		}
+		for _, name := range []string{"go_goroutines", "http_requests_in_flight 1"} {
+			if !strings.Contains(rec.Body.String(), name) {
+				t.Errorf("GET /metrics = missing %q", name)
+			}
+		}
+	})
+
+	t.Run("served on the admin listener", func(t *testing.T) {
+		s := NewServer(WithOptions(Options{
+			Router:        http.NewServeMux(),
+			Log:           &mockLogger{logs: &[]string{}},
+			AdminListener: &listener.Config{Network: listener.NetworkTCP, Address: "127.0.0.1:0"},
+		}))
+		s.routes()
+		s.adminRoutes()
+		rec := httptest.NewRecorder()
+		s.handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET /metrics = %d; want %d", rec.Code, http.StatusNotFound)
+		}
+		rec = httptest.NewRecorder()
+		s.admin.router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET /metrics on admin = %d; want %d", rec.Code, http.StatusOK)
+		}
+	})
+}
+
+// scrapeMetrics returns the lines served by the handler of m that start with any of prefixes.
+func scrapeMetrics(t *testing.T, m *metrics.Metrics, prefixes ...string) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	var lines []string
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(scanner.Text(), prefix) {
+				lines = append(lines, scanner.Text())
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps an http.Handler with additional behaviour.
+type Middleware func(next http.Handler) http.Handler
+
+// chain wraps h with middlewares. The first middleware is the outermost
+// and sees the request first.
+func chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// routeGroup registers routes on a router with a path prefix and
+// middleware shared by all routes in the group.
+type routeGroup struct {
+	router      *http.ServeMux
+	prefix      string
+	middlewares []Middleware
+}
+
+// newRouteGroup returns a routeGroup on router with prefix and middlewares.
+func newRouteGroup(router *http.ServeMux, prefix string, middlewares ...Middleware) *routeGroup {
+	return &routeGroup{
+		router:      router,
+		prefix:      strings.TrimSuffix(prefix, "/"),
+		middlewares: middlewares,
+	}
+}
+
+// Use adds middlewares to the group. They apply to routes registered
+// after the call.
+func (g *routeGroup) Use(middlewares ...Middleware) {
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// Group returns a new group with prefix appended to the prefix of g. It
+// inherits the middleware of g, followed by middlewares.
+func (g *routeGroup) Group(prefix string, middlewares ...Middleware) *routeGroup {
+	mws := make([]Middleware, 0, len(g.middlewares)+len(middlewares))
+	mws = append(mws, g.middlewares...)
+	mws = append(mws, middlewares...)
+	return newRouteGroup(g.router, g.prefix+prefix, mws...)
+}
+
+// Handle registers handler for pattern with the prefix of the group. The pattern
+// follows http.ServeMux, e.g. "GET /users/{id}", and middlewares apply only to
+// this route, inside the middleware of the group.
+func (g *routeGroup) Handle(pattern string, handler http.Handler, middlewares ...Middleware) {
+	mws := make([]Middleware, 0, len(g.middlewares)+len(middlewares))
+	mws = append(mws, g.middlewares...)
+	mws = append(mws, middlewares...)
+	g.router.Handle(g.pattern(pattern), chain(handler, mws...))
+}
+
+// HandleFunc registers handler for pattern, see Handle.
+func (g *routeGroup) HandleFunc(pattern string, handler http.HandlerFunc, middlewares ...Middleware) {
+	g.Handle(pattern, handler, middlewares...)
+}
+
+// pattern inserts the prefix of the group into pattern, after the method
+// and host if they are present.
+func (g *routeGroup) pattern(pattern string) string {
+	if g.prefix == "" {
+		return pattern
+	}
+	var method string
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		method, pattern = pattern[:i+1], strings.TrimLeft(pattern[i+1:], " ")
+	}
+	host, path := "", pattern
+	if i := strings.IndexByte(pattern, '/'); i >= 0 {
+		host, path = pattern[:i], pattern[i:]
+	}
+	return method + host + g.prefix + path
+}
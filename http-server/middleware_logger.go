@@ -1,11 +1,24 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
 	"net"
 	"net/http"
 	"strings"
+
+	"github.com/Zate/go-templates/http-server/internal/clientip"
+	"github.com/Zate/go-templates/http-server/internal/logctx"
 )
 
+// headerRequestID is the header that carries the ID of a request.
+const headerRequestID = "X-Request-Id"
+
+// maxRequestIDLength is the maximum length of a request ID taken from a request.
+const maxRequestIDLength = 128
+
 // loggingResponseWriter is a wrapper around an http.ResponseWriter that keeps
 // track of the status code and length of the response.
 type loggingResponseWriter struct {
@@ -32,32 +45,92 @@ func (w *loggingResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// requestLogger is a middleware that logs the incoming request.
-func requestLogger(log logger, next http.Handler) http.Handler {
+// requestLogger is a middleware that logs the incoming request. The request ID
+// and trace ID of the request are stored in its context, so that records logged
+// with it can be correlated with the request, and the request ID is set on the
+// response. The client IP resolved by clientIP is stored in the context, and
+// logged with its records as remoteIp.
+func requestLogger(log logger, clientIP *clientip.Resolver, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP.Resolve(r)
+		if ip == "" {
+			ip = "N/A"
+		}
+		ctx := logctx.WithAttrs(clientip.NewContext(requestContext(r), ip), slog.String("remoteIp", ip))
+		w.Header().Set(headerRequestID, logctx.RequestID(ctx))
+		r = r.WithContext(ctx)
+
 		lw := &loggingResponseWriter{ResponseWriter: w}
 		next.ServeHTTP(lw, r)
-		log.Info("Request received.", "status", lw.status, "path", r.URL.Path, "method", r.Method, "remoteIp", resolveIP(r))
+		args := []any{"status", lw.status, "path", r.URL.Path, "method", r.Method, "remoteIp", ip}
+		if id, ok := ClientIdentity(r); ok {
+			args = append(args, "clientId", id)
+		}
+		log.InfoContext(ctx, "Request received.", args...)
 	})
 }
 
-// resolveIP checks request for headers Forwarded, X-Forwarded-For, and X-Real-Ip
-// and falls back to the RemoteAddr if none are found.
-func resolveIP(r *http.Request) string {
-	var addr string
-	if f := r.Header.Get("Forwarded"); f != "" {
-		for _, segment := range strings.Split(f, ",") {
-			addr = strings.TrimPrefix(segment, "for=")
-			break
+// requestContext returns the context of r with the request ID of the X-Request-Id
+// header, or a new one if it is missing or invalid, and the trace ID of the W3C
+// traceparent header.
+func requestContext(r *http.Request) context.Context {
+	id := r.Header.Get(headerRequestID)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	ctx := logctx.WithRequestID(r.Context(), id)
+	if traceID, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		ctx = logctx.WithTraceIDs(ctx, traceID, "")
+	}
+	return ctx
+}
+
+// validRequestID reports whether id is a non-empty request ID of printable ASCII
+// characters that is not too long to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
 		}
-	} else if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		addr = strings.Split(xff, ",")[0]
-	} else if xrip := r.Header.Get("X-Real-Ip"); xrip != "" {
-		addr = xrip
-	} else {
-		addr = r.RemoteAddr
 	}
-	ip, _, err := net.SplitHostPort(addr)
+	return true
+}
+
+// newRequestID returns a random request ID of 16 bytes, hex encoded.
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseTraceparent returns the trace ID of a W3C traceparent header of the form
+// version-traceid-parentid-flags, and whether it is valid.
+func parseTraceparent(header string) (string, bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", false
+	}
+	for _, part := range parts[:4] {
+		if _, err := hex.DecodeString(part); err != nil || strings.ToLower(part) != part {
+			return "", false
+		}
+	}
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// resolveIP returns the client IP of r stored by the request logger, or the IP of
+// the peer if it has not been stored.
+func resolveIP(r *http.Request) string {
+	if ip := clientip.FromContext(r.Context()); ip != "" {
+		return ip
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		return "N/A"
 	}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/Zate/go-templates/http-server/internal/clientip"
+	"github.com/Zate/go-templates/http-server/internal/logctx"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -47,6 +53,27 @@ func TestRequestLogger(t *testing.T) {
 			},
 			want: []string{"Request received.", "status", "200", "path", "/", "method", "GET", "remoteIp", "192.168.1.1"},
 		},
+		{
+			name: "log requests ignoring the forwarding headers of untrusted peers",
+			input: struct {
+				status int
+				req    func() *http.Request
+			}{
+				status: http.StatusOK,
+				req: func() *http.Request {
+					req := httptest.NewRequest("GET", "/", nil)
+					req.RemoteAddr = "203.0.113.9:1234"
+					req.Header.Set("Forwarded", "for=192.168.1.1:1234")
+					return req
+				},
+			},
+			want: []string{"Request received.", "status", "200", "path", "/", "method", "GET", "remoteIp", "203.0.113.9"},
+		},
+	}
+	// httptest requests are from 192.0.2.1
+	clientIP, err := clientip.NewResolver([]string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatalf("clientip.NewResolver() error = %v", err)
 	}
 
 	for _, test := range tests {
@@ -65,7 +92,7 @@ func TestRequestLogger(t *testing.T) {
 
 			rr := httptest.NewRecorder()
 			req := test.input.req()
-			requestLogger(log, handler).ServeHTTP(rr, req)
+			requestLogger(log, clientIP, handler).ServeHTTP(rr, req)
 
 			if diff := cmp.Diff(test.want, logs); diff != "" {
 				t.Errorf("requestLogger() = unexpected result, (-want, +got):\n%s\n", diff)
@@ -81,28 +108,19 @@ func TestResolveIP(t *testing.T) {
 		want  string
 	}{
 		{
-			name: "With Forwarded header",
-			input: func() *http.Request {
-				req := httptest.NewRequest("GET", "/", nil)
-				req.Header.Set("Forwarded", "for=192.168.1.1:1234")
-				return req
-			},
-			want: "192.168.1.1",
-		},
-		{
-			name: "With X-Forwarded-For header",
+			name: "With client IP in context",
 			input: func() *http.Request {
 				req := httptest.NewRequest("GET", "/", nil)
-				req.Header.Set("X-Forwarded-For", "192.168.1.1:1234")
-				return req
+				return req.WithContext(clientip.NewContext(req.Context(), "198.51.100.7"))
 			},
-			want: "192.168.1.1",
+			want: "198.51.100.7",
 		},
 		{
-			name: "With X-Real-IP header",
+			name: "With Forwarded header of untrusted peer",
 			input: func() *http.Request {
 				req := httptest.NewRequest("GET", "/", nil)
-				req.Header.Set("X-Real-IP", "192.168.1.1:1234")
+				req.RemoteAddr = "192.168.1.1:1234"
+				req.Header.Set("Forwarded", "for=198.51.100.7")
 				return req
 			},
 			want: "192.168.1.1",
@@ -136,3 +154,90 @@ func TestResolveIP(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestContext(t *testing.T) {
+	var tests = []struct {
+		name          string
+		input         map[string]string
+		wantRequestID string
+		wantTraceID   string
+	}{
+		{
+			name:          "request ID and traceparent headers",
+			input:         map[string]string{"X-Request-Id": "abc-123", "traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			wantRequestID: "abc-123",
+			wantTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:  "invalid request ID and traceparent headers",
+			input: map[string]string{"X-Request-Id": "abc 123", "traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		},
+		{
+			name:  "no headers",
+			input: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range test.input {
+				req.Header.Set(k, v)
+			}
+			var ctx context.Context
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx = r.Context()
+			})
+			rr := httptest.NewRecorder()
+			requestLogger(&mockLogger{logs: &[]string{}}, &clientip.Resolver{}, handler).ServeHTTP(rr, req)
+
+			if got := clientip.FromContext(ctx); got != "192.0.2.1" {
+				t.Errorf("requestLogger() = unexpected client IP, want 192.0.2.1, got: %s", got)
+			}
+
+			gotRequestID := logctx.RequestID(ctx)
+			if test.wantRequestID == "" && len(gotRequestID) != 32 {
+				t.Errorf("requestLogger() = unexpected request ID, want a new one, got: %s", gotRequestID)
+			} else if test.wantRequestID != "" && gotRequestID != test.wantRequestID {
+				t.Errorf("requestLogger() = unexpected request ID, want %s, got: %s", test.wantRequestID, gotRequestID)
+			}
+			if got := rr.Header().Get("X-Request-Id"); got != gotRequestID {
+				t.Errorf("requestLogger() = unexpected X-Request-Id header, want %s, got: %s", gotRequestID, got)
+			}
+			if traceID, _ := logctx.TraceIDs(ctx); traceID != test.wantTraceID {
+				t.Errorf("requestLogger() = unexpected trace ID, want %s, got: %s", test.wantTraceID, traceID)
+			}
+		})
+	}
+}
+
+func TestRequestLogger_ClientIP(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(logctx.NewHandler(slog.NewJSONHandler(&buf, nil), logctx.DefaultKeys))
+	resolver, err := clientip.NewResolver([]string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatalf("clientip.NewResolver() error = %v", err)
+	}
+
+	var got string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientip.FromContext(r.Context())
+		log.InfoContext(r.Context(), "Work done.")
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	requestLogger(log, resolver, handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "198.51.100.7" {
+		t.Errorf("clientip.FromContext() = %q; want 198.51.100.7", got)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]any
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		if record["remoteIp"] != "198.51.100.7" {
+			t.Errorf("record %q remoteIp = %v; want 198.51.100.7", record["msg"], record["remoteIp"])
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/metrics"
+)
+
+// countingReader is a wrapper around a request body that counts the bytes read.
+type countingReader struct {
+	io.ReadCloser
+	n int
+}
+
+// Read acts as an adapter for the body's Read method, and also counts the bytes read.
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.n += n
+	return n, err
+}
+
+// metricsMiddleware is a middleware that records the metrics of requests. The
+// route of a request is the pattern it matches on router, without the method.
+func metricsMiddleware(m *metrics.Metrics, router *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer m.Start()()
+		start := time.Now()
+
+		_, route := router.Handler(r)
+		if _, path, ok := strings.Cut(route, " "); ok {
+			route = path
+		}
+
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+
+		status := lw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.Observe(route, r.Method, status, time.Since(start), body.n, lw.length)
+	})
+}
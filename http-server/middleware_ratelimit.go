@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Zate/go-templates/http-server/internal/ratelimit"
+)
+
+// RateLimitKey returns the key a request is rate limited by. Requests with the same key
+// share a bucket.
+type RateLimitKey func(r *http.Request) string
+
+// KeyByIP rate limits requests by the client IP, see resolveIP.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + resolveIP(r)
+}
+
+// KeyByAPIKey returns a RateLimitKey of the API key in the header name, e.g. X-API-Key.
+// Requests without an API key are limited by the client IP.
+func KeyByAPIKey(name string) RateLimitKey {
+	return func(r *http.Request) string {
+		if key := r.Header.Get(name); key != "" {
+			return "key:" + ratelimit.HashKey(key)
+		}
+		return KeyByIP(r)
+	}
+}
+
+// KeyByRoute returns a RateLimitKey of the pattern a request matches on router, so that
+// all clients share the limit of a route.
+func KeyByRoute(router *http.ServeMux) RateLimitKey {
+	return func(r *http.Request) string {
+		_, route := router.Handler(r)
+		return "route:" + route
+	}
+}
+
+// RateLimitOptions configures the RateLimit middleware.
+type RateLimitOptions struct {
+	// Name separates the buckets of the policy from those of other RateLimit
+	// middlewares on the same Limiter, e.g. the name of the route group.
+	Name   string
+	Policy ratelimit.Policy
+	// Key selects the bucket of a request, defaults to KeyByIP.
+	Key RateLimitKey
+	// Limiter holds the buckets, defaults to a ratelimit.Limiter in memory.
+	Limiter *ratelimit.Limiter
+	// Log logs the errors of the store of the Limiter, requests are allowed when it fails.
+	Log logger
+}
+
+// RateLimit returns a middleware that limits requests with a token bucket per key, e.g.
+// for a route group. Responses have the RateLimit-* headers of the policy, and denied
+// requests are answered with status Too Many Requests and the Retry-After header.
+func RateLimit(options RateLimitOptions) Middleware {
+	if options.Key == nil {
+		options.Key = KeyByIP
+	}
+	if options.Limiter == nil {
+		options.Limiter = ratelimit.NewLimiter(nil)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.Join([]string{options.Name, options.Key(r)}, "|")
+			res, err := options.Limiter.Take(r.Context(), key, options.Policy)
+			if err != nil && options.Log != nil {
+				options.Log.ErrorContext(r.Context(), "Failed to rate limit request.", "error", err.Error())
+			}
+			res.WriteHeaders(w.Header())
+			if !res.Allowed {
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/ratelimit"
+	"github.com/google/go-cmp/cmp"
+)
+
+// failingRedis is a ratelimit.RedisEvaler that fails with err.
+type failingRedis struct {
+	err error
+}
+
+func (f failingRedis) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	return nil, f.err
+}
+
+func TestRateLimit(t *testing.T) {
+	router := http.NewServeMux()
+	var tests = []struct {
+		name     string
+		key      RateLimitKey
+		requests []*http.Request
+		want     []int
+	}{
+		{
+			name: "by ip",
+			key:  KeyByIP,
+			requests: []*http.Request{
+				newTestRequest("GET", "/a", "192.0.2.1:1234", nil),
+				newTestRequest("GET", "/b", "192.0.2.1:1234", nil),
+				newTestRequest("GET", "/a", "192.0.2.2:1234", nil),
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name: "by api key",
+			key:  KeyByAPIKey("X-API-Key"),
+			requests: []*http.Request{
+				newTestRequest("GET", "/a", "192.0.2.1:1234", map[string]string{"X-API-Key": "one"}),
+				newTestRequest("GET", "/a", "192.0.2.2:1234", map[string]string{"X-API-Key": "one"}),
+				newTestRequest("GET", "/a", "192.0.2.1:1234", map[string]string{"X-API-Key": "two"}),
+				newTestRequest("GET", "/a", "192.0.2.1:1234", nil),
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK},
+		},
+		{
+			name: "by route",
+			key:  KeyByRoute(router),
+			requests: []*http.Request{
+				newTestRequest("GET", "/items/1", "192.0.2.1:1234", nil),
+				newTestRequest("GET", "/items/2", "192.0.2.2:1234", nil),
+				newTestRequest("GET", "/a", "192.0.2.1:1234", nil),
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+	}
+	router.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	router.HandleFunc("GET /a", func(w http.ResponseWriter, r *http.Request) {})
+	router.HandleFunc("GET /b", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := chain(router, RateLimit(RateLimitOptions{
+				Policy: ratelimit.Policy{Limit: 1, Period: time.Hour},
+				Key:    test.key,
+			}))
+			var got []int
+			for _, req := range test.requests {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				got = append(got, rec.Code)
+				if rec.Header().Get("RateLimit-Limit") != "1" {
+					t.Errorf("RateLimit-Limit = %q; want 1", rec.Header().Get("RateLimit-Limit"))
+				}
+				if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+					t.Errorf("Retry-After = missing")
+				}
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("RateLimit() = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestRateLimit_RouteGroups(t *testing.T) {
+	router := http.NewServeMux()
+	limiter := ratelimit.NewLimiter(nil)
+	api := newRouteGroup(router, "/api", RateLimit(RateLimitOptions{Name: "api", Policy: ratelimit.Policy{Limit: 1, Period: time.Hour}, Limiter: limiter}))
+	api.HandleFunc("GET /items", func(w http.ResponseWriter, r *http.Request) {})
+	search := newRouteGroup(router, "/search", RateLimit(RateLimitOptions{Name: "search", Policy: ratelimit.Policy{Limit: 2, Period: time.Hour}, Limiter: limiter}))
+	search.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {})
+	router.HandleFunc("GET /livez", func(w http.ResponseWriter, r *http.Request) {})
+
+	var got []int
+	for _, path := range []string{"/api/items", "/api/items", "/search/", "/search/", "/search/", "/livez", "/livez"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, newTestRequest("GET", path, "192.0.2.1:1234", nil))
+		got = append(got, rec.Code)
+	}
+	want := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RateLimit() = unexpected result (-want +got):\n%s\n", diff)
+	}
+}
+
+func TestRateLimit_StoreError(t *testing.T) {
+	log := &mockLogger{logs: &[]string{}}
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), RateLimit(RateLimitOptions{
+		Policy:  ratelimit.Policy{Limit: 1, Period: time.Hour},
+		Limiter: ratelimit.NewLimiter(ratelimit.NewRedisStore(failingRedis{err: http.ErrServerClosed}, "")),
+		Log:     log,
+	}))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newTestRequest("GET", "/", "192.0.2.1:1234", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET / = %d; want %d when the store fails", rec.Code, http.StatusOK)
+		}
+	}
+	want := []string{"Failed to rate limit request.", "error", "rate limit: http: Server closed"}
+	if diff := cmp.Diff(want, (*log.logs)[:3]); diff != "" {
+		t.Errorf("logs = unexpected result (-want +got):\n%s\n", diff)
+	}
+}
+
+// newTestRequest returns a request from remoteAddr with headers.
+func newTestRequest(method, target, remoteAddr string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return req
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverer is a middleware that recovers from panics in next, logs them
+// and responds with status Internal Server Error.
+func recoverer(log logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// http.ErrAbortHandler is used to abort a response and should not be recovered.
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			log.ErrorContext(r.Context(), "Recovered from panic.", "panic", fmt.Sprint(rec), "path", r.URL.Path, "method", r.Method, "stack", string(debug.Stack()))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecoverer(t *testing.T) {
+	logs := []string{}
+	log := &mockLogger{
+		logs: &logs,
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+
+	rr := httptest.NewRecorder()
+	recoverer(log, handler).ServeHTTP(rr, httptest.NewRequest("GET", "/panic", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusInternalServerError)
+	}
+	want := []string{"Recovered from panic.", "panic", "oops", "path", "/panic", "method", "GET", "stack"}
+	if len(logs) != len(want)+1 {
+		t.Fatalf("recoverer() = unexpected logs: %v", logs)
+	}
+	if diff := cmp.Diff(want, logs[:len(want)]); diff != "" {
+		t.Errorf("recoverer() = unexpected result (-want +got):\n%s\n", diff)
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	logs := []string{}
+	srv := NewServer(WithOptions(Options{
+		Router: http.NewServeMux(),
+		Log: &mockLogger{
+			logs: &logs,
+		},
+		Middleware: []Middleware{
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Server", "template")
+					next.ServeHTTP(w, r)
+				})
+			},
+		},
+	}))
+	srv.group("").HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/panic", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	srv.handler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if got := rr.Header().Get("X-Server"); got != "template" {
+		t.Errorf("X-Server = %q; want %q", got, "template")
+	}
+	want := []string{"Request received.", "status", "500", "path", "/panic", "method", "GET", "remoteIp", "192.168.1.1"}
+	if diff := cmp.Diff(want, logs[len(logs)-len(want):]); diff != "" {
+		t.Errorf("handler() = unexpected result (-want +got):\n%s\n", diff)
+	}
+}
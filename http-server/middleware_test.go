@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+
+	chain(handler, mw("first"), mw("second")).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	want := []string{"first", "second", "handler"}
+	if diff := cmp.Diff(want, calls); diff != "" {
+		t.Errorf("chain() = unexpected result (-want +got):\n%s\n", diff)
+	}
+}
+
+func TestRouteGroup(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			method string
+			path   string
+		}
+		want struct {
+			status int
+			body   string
+		}
+	}{
+		{
+			name: "route without group",
+			input: struct {
+				method string
+				path   string
+			}{method: "GET", path: "/ping"},
+			want: struct {
+				status int
+				body   string
+			}{status: http.StatusOK, body: "root:ping"},
+		},
+		{
+			name: "route in group",
+			input: struct {
+				method string
+				path   string
+			}{method: "GET", path: "/api/users/42"},
+			want: struct {
+				status int
+				body   string
+			}{status: http.StatusOK, body: "root,api:user 42"},
+		},
+		{
+			name: "route in nested group with route middleware",
+			input: struct {
+				method string
+				path   string
+			}{method: "POST", path: "/api/v1/users"},
+			want: struct {
+				status int
+				body   string
+			}{status: http.StatusCreated, body: "root,api,v1,route:created"},
+		},
+		{
+			name: "method not allowed",
+			input: struct {
+				method string
+				path   string
+			}{method: "DELETE", path: "/api/users/42"},
+			want: struct {
+				status int
+				body   string
+			}{status: http.StatusMethodNotAllowed, body: "Method Not Allowed\n"},
+		},
+		{
+			name: "not found",
+			input: struct {
+				method string
+				path   string
+			}{method: "GET", path: "/users/42"},
+			want: struct {
+				status int
+				body   string
+			}{status: http.StatusNotFound, body: "404 page not found\n"},
+		},
+	}
+
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.Header.Add("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	respond := func(status int, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(strings.Join(r.Header.Values("X-Middleware"), ",") + ":" + strings.ReplaceAll(body, "{id}", r.PathValue("id"))))
+		}
+	}
+
+	router := http.NewServeMux()
+	root := newRouteGroup(router, "", tag("root"))
+	root.HandleFunc("GET /ping", respond(http.StatusOK, "ping"))
+	api := root.Group("/api/", tag("api"))
+	api.HandleFunc("GET /users/{id}", respond(http.StatusOK, "user {id}"))
+	v1 := api.Group("/v1")
+	v1.Use(tag("v1"))
+	v1.HandleFunc("POST /users", respond(http.StatusCreated, "created"), tag("route"))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(test.input.method, test.input.path, nil))
+
+			if test.want.status != rr.Code {
+				t.Errorf("status = %d; want %d", rr.Code, test.want.status)
+			}
+			if diff := cmp.Diff(test.want.body, rr.Body.String()); diff != "" {
+				t.Errorf("body = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestRouteGroup_Pattern(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			prefix  string
+			pattern string
+		}
+		want string
+	}{
+		{
+			name: "no prefix",
+			input: struct {
+				prefix  string
+				pattern string
+			}{pattern: "GET /users"},
+			want: "GET /users",
+		},
+		{
+			name: "path",
+			input: struct {
+				prefix  string
+				pattern string
+			}{prefix: "/api", pattern: "/users/"},
+			want: "/api/users/",
+		},
+		{
+			name: "method",
+			input: struct {
+				prefix  string
+				pattern string
+			}{prefix: "/api/", pattern: "GET /users/{id}"},
+			want: "GET /api/users/{id}",
+		},
+		{
+			name: "method and host",
+			input: struct {
+				prefix  string
+				pattern string
+			}{prefix: "/api", pattern: "GET example.com/{$}"},
+			want: "GET example.com/api/{$}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := newRouteGroup(http.NewServeMux(), test.input.prefix).pattern(test.input.pattern)
+			if test.want != got {
+				t.Errorf("pattern(%q) = %q; want %q", test.input.pattern, got, test.want)
+			}
+		})
+	}
+}
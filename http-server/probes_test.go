@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/listener"
+	"github.com/Zate/go-templates/http-server/internal/probes"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProbes(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			setup  func(p *probes.Probes)
+			target string
+		}
+		want struct {
+			status int
+			body   string
+		}
+	}{
+		{
+			name: "livez",
+			input: struct {
+				setup  func(p *probes.Probes)
+				target string
+			}{
+				setup:  func(p *probes.Probes) {},
+				target: "/livez",
+			},
+			want: struct {
+				status int
+				body   string
+			}{
+				status: http.StatusOK,
+				body:   "ok",
+			},
+		},
+		{
+			name: "livez verbose",
+			input: struct {
+				setup  func(p *probes.Probes)
+				target string
+			}{
+				setup:  func(p *probes.Probes) {},
+				target: "/livez?verbose",
+			},
+			want: struct {
+				status int
+				body   string
+			}{
+				status: http.StatusOK,
+				body:   "[+]ping ok\nlivez check passed\n",
+			},
+		},
+		{
+			name: "readyz before started",
+			input: struct {
+				setup  func(p *probes.Probes)
+				target string
+			}{
+				setup:  func(p *probes.Probes) {},
+				target: "/readyz",
+			},
+			want: struct {
+				status int
+				body   string
+			}{
+				status: http.StatusServiceUnavailable,
+				body:   "[-]started failed: not started\n[+]shutdown ok\nreadyz check failed\n",
+			},
+		},
+		{
+			name: "readyz started",
+			input: struct {
+				setup  func(p *probes.Probes)
+				target string
+			}{
+				setup: func(p *probes.Probes) {
+					p.SetStarted()
+					p.AddReadinessCheck("database", func(ctx context.Context) error { return nil })
+				},
+				target: "/readyz?verbose",
+			},
+			want: struct {
+				status int
+				body   string
+			}{
+				status: http.StatusOK,
+				body:   "[+]started ok\n[+]shutdown ok\n[+]database ok\nreadyz check passed\n",
+			},
+		},
+		{
+			name: "readyz with dependency down",
+			input: struct {
+				setup  func(p *probes.Probes)
+				target string
+			}{
+				setup: func(p *probes.Probes) {
+					p.SetStarted()
+					p.AddReadinessCheck("database", func(ctx context.Context) error { return errors.New("connection refused") })
+				},
+				target: "/readyz",
+			},
+			want: struct {
+				status int
+				body   string
+			}{
+				status: http.StatusServiceUnavailable,
+				body:   "[+]started ok\n[+]shutdown ok\n[-]database failed: connection refused\nreadyz check failed\n",
+			},
+		},
+		{
+			name: "readyz shutting down",
+			input: struct {
+				setup  func(p *probes.Probes)
+				target string
+			}{
+				setup: func(p *probes.Probes) {
+					p.SetStarted()
+					p.SetShuttingDown()
+				},
+				target: "/readyz",
+			},
+			want: struct {
+				status int
+				body   string
+			}{
+				status: http.StatusServiceUnavailable,
+				body:   "[+]started ok\n[-]shutdown failed: shutting down\nreadyz check failed\n",
+			},
+		},
+		{
+			name: "startupz started",
+			input: struct {
+				setup  func(p *probes.Probes)
+				target string
+			}{
+				setup:  func(p *probes.Probes) { p.SetStarted() },
+				target: "/startupz",
+			},
+			want: struct {
+				status int
+				body   string
+			}{
+				status: http.StatusOK,
+				body:   "ok",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := NewServer()
+			test.input.setup(srv.probes)
+			srv.routes()
+
+			rr := httptest.NewRecorder()
+			srv.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, test.input.target, nil))
+
+			if test.want.status != rr.Code {
+				t.Errorf("probe status = unexpected result, want %d, got %d", test.want.status, rr.Code)
+			}
+			if diff := cmp.Diff(test.want.body, rr.Body.String()); diff != "" {
+				t.Errorf("probe body = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestServer_Start_ShutdownDelay(t *testing.T) {
+	addr := "127.0.0.1:" + strconv.Itoa(freeTestPort(t))
+	srv := NewServer(WithOptions(Options{
+		Router:        http.NewServeMux(),
+		Log:           &mockLogger{logs: &[]string{}},
+		Listeners:     []listener.Config{{Network: listener.NetworkTCP, Address: addr}},
+		ShutdownDelay: 500 * time.Millisecond,
+	}))
+
+	readyz := func() int {
+		resp, err := http.Get("http://" + addr + "/readyz")
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	results := make(chan []int, 1)
+	go func() {
+		var got []int
+		for i := 0; i < 100 && readyz() != http.StatusOK; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		got = append(got, http.StatusOK)
+		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+		// the failing readiness probe is served until the delay has passed
+		for i := 0; i < 100; i++ {
+			if status := readyz(); status == http.StatusServiceUnavailable {
+				got = append(got, status)
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		results <- got
+	}()
+
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start() = unexpected error: %v", err)
+	}
+
+	want := []int{http.StatusOK, http.StatusServiceUnavailable}
+	if diff := cmp.Diff(want, <-results); diff != "" {
+		t.Errorf("Start() = unexpected readiness (-want +got):\n%s\n", diff)
+	}
+}
@@ -0,0 +1,190 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/levels"
+	"github.com/Zate/go-templates/http-server/internal/listener"
+)
+
+// envRestartHelper holds the address TestRestartHelper listens on.
+const envRestartHelper = "HTTP_SERVER_RESTART_HELPER"
+
+// TestRestartHelper is not a test, it runs the server for TestServer_Restart in a
+// separate process, and again in the process started by the restart. It logs JSON to
+// stderr, the records TestServer_Restart waits for.
+func TestRestartHelper(t *testing.T) {
+	addr := os.Getenv(envRestartHelper)
+	if addr == "" {
+		t.Skip("helper process for TestServer_Restart")
+	}
+
+	log := NewLogger(levels.NewController(slog.LevelInfo))
+	shuttingDown := make(chan struct{})
+	router := http.NewServeMux()
+	router.HandleFunc("GET /pid", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, os.Getpid())
+	})
+	// /slow is in flight until the server shuts down after the restart.
+	router.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		log.Info("Slow request received.")
+		<-shuttingDown
+		fmt.Fprint(w, os.Getpid())
+	})
+	srv := NewServer(WithOptions(Options{
+		Router:    router,
+		Log:       log,
+		Listeners: []listener.Config{{Network: listener.NetworkTCP, Address: addr}},
+	}))
+	srv.httpServer.RegisterOnShutdown(func() { close(shuttingDown) })
+	if err := srv.Start(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestServer_Restart(t *testing.T) {
+	addr := "127.0.0.1:" + strconv.Itoa(freeTestPort(t))
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRestartHelper$")
+	cmd.Env = append(os.Environ(), envRestartHelper+"="+addr)
+	// Both processes log to the pipe, it is closed once the last one exits.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	cmd.Stderr = w
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	records := make(chan map[string]any, 100)
+	go func() {
+		defer close(records)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			// request logs are not waited for, the pipe is drained so that
+			// the processes do not block writing them
+			var record map[string]any
+			if json.Unmarshal(scanner.Bytes(), &record) == nil && record["msg"] != "Request received." {
+				records <- record
+			}
+		}
+	}()
+	// waitLog waits for the next record with the message msg.
+	waitLog := func(msg string) map[string]any {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case record, ok := <-records:
+				if !ok {
+					t.Fatalf("server exited before logging %q", msg)
+				}
+				if record["msg"] == msg {
+					return record
+				}
+			case <-timeout:
+				cmd.Process.Kill()
+				t.Fatalf("server did not log %q", msg)
+			}
+		}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	get := func(path string) (string, error) {
+		resp, err := client.Get("http://" + addr + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	waitLog("Server started.")
+	parent, err := get("/pid")
+	if err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("server is not serving once started: %v", err)
+	}
+
+	// A request in flight during the restart is served by the old process,
+	// and requests keep being served while the processes are swapped.
+	var wg sync.WaitGroup
+	var slow string
+	var slowErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slow, slowErr = get("/slow")
+	}()
+	waitLog("Slow request received.")
+	done := make(chan struct{})
+	var failed []error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := get("/pid"); err != nil {
+				failed = append(failed, err)
+			}
+		}
+	}()
+
+	cmd.Process.Signal(syscall.SIGHUP)
+	restarted := waitLog("Server restarted.")
+	child, _ := restarted["pid"].(string)
+	defer func() {
+		pid, _ := strconv.Atoi(child)
+		syscall.Kill(pid, syscall.SIGTERM)
+	}()
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("old process exited with %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("old process did not exit after restart")
+	}
+	close(done)
+	wg.Wait()
+
+	if got, err := get("/pid"); err != nil || got != child {
+		t.Errorf("pid after restart = %q, %v; want %q", got, err, child)
+	}
+	if slowErr != nil || slow != parent {
+		t.Errorf("in flight request = %q, %v; want %q, nil", slow, slowErr, parent)
+	}
+	if child == parent {
+		t.Errorf("pid after restart = %s; want a new process", child)
+	}
+	if len(failed) > 0 {
+		t.Errorf("%d requests failed during restart, first: %v", len(failed), failed[0])
+	}
+}
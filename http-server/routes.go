@@ -1,3 +1,57 @@
 package main
 
-func (s server) routes() {}
+import (
+	"github.com/Zate/go-templates/http-server/internal/diagnostics"
+)
+
+func (s server) routes() {
+	r := s.group("")
+	r.Handle("GET /livez", s.probes.LivezHandler())
+	r.Handle("GET /readyz", s.probes.ReadyzHandler())
+	r.Handle("GET /startupz", s.probes.StartupzHandler())
+	if s.metrics != nil && s.admin == nil {
+		r.Handle("GET /metrics", s.metrics.Handler())
+	}
+	if s.admin == nil {
+		s.levelRoutes(r)
+	}
+}
+
+// adminRoutes registers the routes of the admin server, such as debug and
+// metrics endpoints. It is only called when an admin listener is set.
+func (s server) adminRoutes() {
+	r := newRouteGroup(s.admin.router, "")
+	r.Handle("GET /livez", s.probes.LivezHandler())
+	r.Handle("GET /readyz", s.probes.ReadyzHandler())
+	r.Handle("GET /startupz", s.probes.StartupzHandler())
+	if s.metrics != nil {
+		r.Handle("GET /metrics", s.metrics.Handler())
+	}
+	s.levelRoutes(r)
+	s.diagnosticsRoutes(r)
+}
+
+// diagnosticsRoutes registers the diagnostics.Handler on r under /debug/, protected by
+// the admin token. Without an admin token it is only registered if the admin listener
+// accepts local connections only.
+func (s server) diagnosticsRoutes(r *routeGroup) {
+	var middlewares []Middleware
+	switch {
+	case s.adminToken != "":
+		middlewares = append(middlewares, RequireToken(s.adminToken))
+	case !s.admin.local():
+		return
+	}
+	r.Handle("/debug/", diagnostics.Handler(), middlewares...)
+}
+
+// levelRoutes registers the /loglevel endpoint of the levels of the server on r,
+// protected by the admin token. It is not registered without an admin token.
+func (s server) levelRoutes(r *routeGroup) {
+	if s.levels == nil || s.adminToken == "" {
+		return
+	}
+	auth := RequireToken(s.adminToken)
+	r.Handle("GET /loglevel", s.levels.Handler(), auth)
+	r.Handle("PUT /loglevel", s.levels.Handler(), auth)
+}
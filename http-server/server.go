@@ -2,11 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/clientip"
+	"github.com/Zate/go-templates/http-server/internal/diagnostics"
+	"github.com/Zate/go-templates/http-server/internal/levels"
+	"github.com/Zate/go-templates/http-server/internal/listener"
+	"github.com/Zate/go-templates/http-server/internal/metrics"
+	"github.com/Zate/go-templates/http-server/internal/probes"
+	"github.com/Zate/go-templates/http-server/internal/transport"
 )
 
 // Defaults for server configuration.
@@ -16,6 +29,9 @@ const (
 	defaultReadTimeout  = 15 * time.Second
 	defaultWriteTimeout = 15 * time.Second
 	defaultIdleTimeout  = 30 * time.Second
+	// defaultShutdownTimeout is how long a graceful shutdown waits for in-flight
+	// requests to drain.
+	defaultShutdownTimeout = 15 * time.Second
 )
 
 // server holds an http.Server, a router and it's configured options.
@@ -23,17 +39,88 @@ type server struct {
 	httpServer *http.Server
 	router     *http.ServeMux
 	log        logger
+	probes     *probes.Probes
+	metrics    *metrics.Metrics
+	levels     *levels.Controller
+	adminToken string
+	clientIP   *clientip.Resolver
+	middleware []Middleware
+	tls        *TLSOptions
+	http2      *HTTP2Options
+	transports []transport.Transport
+	listeners  []listener.Config
+	admin      *adminServer
+
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+	restartTimeout  time.Duration
+}
+
+// adminServer serves debug and metrics endpoints on a separate listener.
+type adminServer struct {
+	httpServer *http.Server
+	router     *http.ServeMux
+	listener   listener.Config
+}
+
+// local reports whether the admin listener only accepts local connections, on the
+// loopback interface or a unix socket.
+func (a *adminServer) local() bool {
+	switch a.listener.Network {
+	case listener.NetworkTCP, "":
+		return diagnostics.LoopbackAddress(a.listener.Address)
+	case listener.NetworkUnix:
+		return true
+	}
+	return false
 }
 
 // Options holds the configuration for the server.
 type Options struct {
-	Router       *http.ServeMux
-	Log          logger
-	Host         string
-	Port         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	IdleTimeout  time.Duration
+	Router *http.ServeMux
+	Log    logger
+	Probes *probes.Probes
+	// Metrics records the metrics of requests and is served on /metrics, on the
+	// admin listener if it is set. Defaults to metrics.New.
+	Metrics *metrics.Metrics
+	// Levels controls the levels of the default logger at runtime, through SIGUSR1
+	// and the /loglevel endpoint. Defaults to a levels.Controller at info level.
+	Levels *levels.Controller
+	// AdminToken is the bearer token of the admin endpoints, such as /loglevel.
+	// They are disabled if it is empty.
+	AdminToken string
+	// ClientIP resolves the client IP of requests, honoring the forwarding headers of
+	// trusted proxies. Defaults to the IP of the peer.
+	ClientIP *clientip.Resolver
+	// Middleware wraps all requests, inside the request logger and recoverer.
+	Middleware []Middleware
+	// TLS enables TLS, and mutual TLS if a client CA is set.
+	TLS *TLSOptions
+	// HTTP2 configures HTTP/2 and enables h2c.
+	HTTP2 *HTTP2Options
+	// Transports serve the handler of the server alongside its http.Server,
+	// e.g. HTTP/3 over QUIC.
+	Transports []transport.Transport
+	// Listeners are the addresses the server listens on, defaults to Host:Port.
+	Listeners []listener.Config
+	// AdminListener enables a separate server for debug and metrics endpoints,
+	// registered in adminRoutes. It serves the diagnostics.Handler if AdminToken is
+	// set, or if it only accepts local connections.
+	AdminListener *listener.Config
+	Host          string
+	Port          string
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	IdleTimeout   time.Duration
+	// ShutdownDelay is how long a graceful shutdown fails the readiness probe before
+	// draining starts, so that load balancers stop sending traffic to the server.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout is how long a graceful shutdown waits for in-flight requests
+	// to drain. Defaults to 15 seconds.
+	ShutdownTimeout time.Duration
+	// RestartTimeout is how long a restart on SIGHUP or SIGUSR2 waits for the new
+	// process to be ready. Defaults to listener.DefaultRestartTimeout.
+	RestartTimeout time.Duration
 }
 
 // Option is a function that configures the server.
@@ -56,12 +143,30 @@ func NewServer(options ...Option) *server {
 		s.router = http.NewServeMux()
 		s.httpServer.Handler = s.router
 	}
+	if s.levels == nil {
+		s.levels = levels.NewController(slog.LevelInfo)
+	}
 	if s.log == nil {
-		s.log = NewDefaultLogger()
+		s.log = NewLogger(s.levels)
+	}
+	if s.probes == nil {
+		s.probes = probes.New()
+	}
+	if s.clientIP == nil {
+		s.clientIP = &clientip.Resolver{}
+	}
+	if s.metrics == nil {
+		s.metrics = metrics.New()
 	}
 	if len(s.httpServer.Addr) == 0 {
 		s.httpServer.Addr = defaultHost + ":" + defaultPort
 	}
+	if s.shutdownTimeout == 0 {
+		s.shutdownTimeout = defaultShutdownTimeout
+	}
+	if s.restartTimeout == 0 {
+		s.restartTimeout = listener.DefaultRestartTimeout
+	}
 
 	return s
 }
@@ -69,46 +174,214 @@ func NewServer(options ...Option) *server {
 // Start the server.
 func (s server) Start() error {
 	s.routes()
+	handler := s.handler()
+	s.httpServer.Handler = handler
 
-	errCh := make(chan error, 1)
-	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- err
-			return
+	if s.tls != nil {
+		cfg, err := s.tlsConfig()
+		if err != nil {
+			s.log.Error("Failed to start server.", "error", err.Error())
+			return err
 		}
-	}()
+		s.httpServer.TLSConfig = cfg
+	}
+	if s.http2 != nil {
+		h, err := s.configureHTTP2(handler, *s.http2)
+		if err != nil {
+			s.log.Error("Failed to start server.", "error", err.Error())
+			return err
+		}
+		s.httpServer.Handler = h
+	}
 
-	select {
-	case err := <-errCh:
-		s.log.Error("Failed to start server.")
+	listeners, names, err := listener.ListenNamed(s.listenerConfigs())
+	if err != nil {
+		s.log.Error("Failed to start server.", "error", err.Error())
 		return err
-	case <-time.After(10 * time.Millisecond):
-		s.log.Info("Server started.", "address", s.httpServer.Addr)
+	}
+	var adminListeners []net.Listener
+	if s.admin != nil {
+		s.adminRoutes()
+		s.admin.httpServer.Handler = recoverer(s.log, s.admin.router)
+		if adminListeners, err = listener.Listen(s.admin.listener); err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			s.log.Error("Failed to start server.", "error", err.Error())
+			return err
+		}
+		for range adminListeners {
+			names = append(names, s.admin.listener.String())
+		}
+	}
+
+	// Signals are handled from now on, so that a signal received once the server
+	// is ready, e.g. a restart, does not terminate the process.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(stop)
+
+	errCh := make(chan error, len(listeners)+len(adminListeners)+len(s.transports))
+	// http.Server sets a TLSConfig when it configures HTTP/2 on the first call to
+	// Serve, so whether to serve TLS is decided before serving any listener.
+	useTLS := s.httpServer.TLSConfig != nil
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			if err := s.serve(ln, useTLS); err != nil && err != http.ErrServerClosed {
+				errCh <- err
+			}
+		}(ln)
+	}
+	for _, ln := range adminListeners {
+		go func(ln net.Listener) {
+			if err := s.admin.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+				errCh <- err
+			}
+		}(ln)
+	}
+	for _, t := range s.transports {
+		go func(t transport.Transport) {
+			if err := t.Serve(handler); err != nil && err != http.ErrServerClosed {
+				errCh <- err
+			}
+		}(t)
+	}
+
+	// The listeners are bound and served: connections are queued until they are
+	// accepted, so the server is ready. Errors of Serve from now on stop the server.
+	s.probes.SetStarted()
+	args := []any{"address", listenerAddresses(s.listenerConfigs())}
+	if s.admin != nil {
+		args = append(args, "adminAddress", listenerAddresses([]listener.Config{s.admin.listener}))
+	}
+	s.log.Info("Server started.", args...)
+	if err := listener.NotifyReady(); err != nil {
+		s.log.Error("Failed to notify the previous process.", "error", err.Error())
 	}
 
-	sig, err := s.shutdown()
+	reason, err := s.wait(stop, errCh, append(listeners, adminListeners...), names)
 	if err != nil {
+		s.log.Error("Server failed.", "error", err.Error())
+	}
+	if shutdownErr := s.shutdown(); shutdownErr != nil {
 		s.log.Error("Failed to shutdown server gracefully.")
-		return err
+		return errors.Join(err, shutdownErr)
 	}
-	s.log.Info("Server shutdown.", "reason", sig.String())
-	return nil
+	s.log.Info("Server shutdown.", "reason", reason)
+	return err
 }
 
-// shutdown the server.
-func (s server) shutdown() (os.Signal, error) {
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-stop
+// serve serves on ln, with TLS if useTLS is true.
+func (s server) serve(ln net.Listener, useTLS bool) error {
+	if useTLS {
+		return s.httpServer.ServeTLS(ln, "", "")
+	}
+	return s.httpServer.Serve(ln)
+}
+
+// listenerConfigs returns the listeners of the server, or Host:Port if none are set.
+func (s server) listenerConfigs() []listener.Config {
+	if len(s.listeners) > 0 {
+		return s.listeners
+	}
+	return []listener.Config{{Network: listener.NetworkTCP, Address: s.httpServer.Addr}}
+}
+
+// listenerAddresses returns the addresses of configs for logging, prefixed
+// with the network unless it is tcp.
+func listenerAddresses(configs []listener.Config) string {
+	addresses := make([]string, 0, len(configs))
+	for _, c := range configs {
+		if c.Network == listener.NetworkTCP || c.Network == "" {
+			addresses = append(addresses, c.Address)
+			continue
+		}
+		addresses = append(addresses, c.String())
+	}
+	return strings.Join(addresses, ",")
+}
+
+// handler returns the handler of the server wrapped with the request logger,
+// the metrics, the recoverer and the middleware of the server.
+func (s server) handler() http.Handler {
+	h := s.httpServer.Handler
+	if h == nil {
+		h = s.router
+	}
+	middlewares := []Middleware{
+		func(next http.Handler) http.Handler { return requestLogger(s.log, s.clientIP, next) },
+	}
+	if s.metrics != nil {
+		middlewares = append(middlewares, func(next http.Handler) http.Handler { return metricsMiddleware(s.metrics, s.router, next) })
+	}
+	middlewares = append(middlewares, func(next http.Handler) http.Handler { return recoverer(s.log, next) })
+	return chain(h, append(middlewares, s.middleware...)...)
+}
+
+// group returns a routeGroup on the router of the server with prefix and middlewares.
+func (s server) group(prefix string, middlewares ...Middleware) *routeGroup {
+	return newRouteGroup(s.router, prefix, middlewares...)
+}
+
+// wait blocks until the server has to shut down, on a signal received on stop or
+// an error of Serve received on errCh, and returns the reason. On SIGHUP or SIGUSR2
+// the server is restarted: listeners named names are handed off to a new process,
+// and once it is ready wait returns. If the restart fails the server keeps serving.
+// SIGUSR1 toggles debug logging.
+func (s server) wait(stop <-chan os.Signal, errCh <-chan error, listeners []net.Listener, names []string) (string, error) {
+	for {
+		select {
+		case err := <-errCh:
+			return "server error", err
+		case sig := <-stop:
+			if sig == syscall.SIGUSR1 {
+				s.toggleDebug()
+				continue
+			}
+			if sig != syscall.SIGHUP && sig != syscall.SIGUSR2 {
+				return sig.String(), nil
+			}
+			pid, err := listener.Restart(listeners, names, s.restartTimeout)
+			if err != nil {
+				s.log.Error("Failed to restart server.", "error", err.Error())
+				continue
+			}
+			s.log.Info("Server restarted.", "pid", strconv.Itoa(pid))
+			return sig.String(), nil
+		}
+	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+// shutdown the server gracefully, failing the readiness probe for the shutdown
+// delay and then draining in-flight requests for up to the shutdown timeout.
+func (s server) shutdown() error {
+	s.probes.SetShuttingDown()
+	if s.shutdownDelay > 0 {
+		time.Sleep(s.shutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
 	s.httpServer.SetKeepAlivesEnabled(false)
-	if err := s.httpServer.Shutdown(ctx); err != nil {
-		return nil, err
+	errs := []error{s.httpServer.Shutdown(ctx)}
+	for _, t := range s.transports {
+		errs = append(errs, t.Shutdown(ctx))
 	}
-	return sig, nil
+	if s.admin != nil {
+		errs = append(errs, s.admin.httpServer.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// toggleDebug ends debug logging if it is enabled, or enables it for levels.DefaultDebugDuration.
+func (s server) toggleDebug() {
+	until := s.levels.ToggleDebug(levels.DefaultDebugDuration)
+	if until.IsZero() {
+		s.log.Info("Debug logging disabled.")
+		return
+	}
+	s.log.Info("Debug logging enabled.", "until", until.Format(time.RFC3339))
 }
 
 // WithOptions configures the server with the given Options.
@@ -116,6 +389,31 @@ func WithOptions(options Options) Option {
 	return func(s *server) {
 		s.router = options.Router
 		s.log = options.Log
+		s.probes = options.Probes
+		s.metrics = options.Metrics
+		s.levels = options.Levels
+		s.adminToken = options.AdminToken
+		s.clientIP = options.ClientIP
+		s.middleware = options.Middleware
+		s.tls = options.TLS
+		s.http2 = options.HTTP2
+		s.transports = options.Transports
+		s.listeners = options.Listeners
+		s.shutdownDelay = options.ShutdownDelay
+		s.shutdownTimeout = options.ShutdownTimeout
+		s.restartTimeout = options.RestartTimeout
+		if options.AdminListener != nil {
+			router := http.NewServeMux()
+			s.admin = &adminServer{
+				httpServer: &http.Server{
+					ReadTimeout:  options.ReadTimeout,
+					WriteTimeout: options.WriteTimeout,
+					IdleTimeout:  options.IdleTimeout,
+				},
+				router:   router,
+				listener: *options.AdminListener,
+			}
+		}
 
 		s.httpServer.Handler = options.Router
 		s.httpServer.Addr = options.Host + ":" + options.Port
@@ -6,12 +6,19 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/Zate/go-templates/http-server/internal/clientip"
+	"github.com/Zate/go-templates/http-server/internal/levels"
+	"github.com/Zate/go-templates/http-server/internal/listener"
+	"github.com/Zate/go-templates/http-server/internal/metrics"
+	"github.com/Zate/go-templates/http-server/internal/probes"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestNew(t *testing.T) {
@@ -31,8 +38,15 @@ func TestNew(t *testing.T) {
 					WriteTimeout: defaultWriteTimeout,
 					IdleTimeout:  defaultIdleTimeout,
 				},
-				router: &http.ServeMux{},
-				log:    NewDefaultLogger(),
+				router:   &http.ServeMux{},
+				log:      NewLogger(levels.NewController(slog.LevelInfo)),
+				probes:   probes.New(),
+				metrics:  metrics.New(),
+				levels:   levels.NewController(slog.LevelInfo),
+				clientIP: &clientip.Resolver{},
+
+				shutdownTimeout: defaultShutdownTimeout,
+				restartTimeout:  listener.DefaultRestartTimeout,
 			},
 		},
 		{
@@ -46,6 +60,10 @@ func TestNew(t *testing.T) {
 					ReadTimeout:  10 * time.Second,
 					WriteTimeout: 10 * time.Second,
 					IdleTimeout:  15 * time.Second,
+
+					ShutdownDelay:   time.Second,
+					ShutdownTimeout: 5 * time.Second,
+					RestartTimeout:  time.Minute,
 				}),
 			},
 			want: &server{
@@ -56,8 +74,16 @@ func TestNew(t *testing.T) {
 					WriteTimeout: 10 * time.Second,
 					IdleTimeout:  15 * time.Second,
 				},
-				router: &http.ServeMux{},
-				log:    NewDefaultLogger(),
+				router:   &http.ServeMux{},
+				log:      NewDefaultLogger(),
+				probes:   probes.New(),
+				metrics:  metrics.New(),
+				levels:   levels.NewController(slog.LevelInfo),
+				clientIP: &clientip.Resolver{},
+
+				shutdownDelay:   time.Second,
+				shutdownTimeout: 5 * time.Second,
+				restartTimeout:  time.Minute,
 			},
 		},
 	}
@@ -69,7 +95,7 @@ func TestNew(t *testing.T) {
 				t.Errorf("New(%v) = nil; want %v", test.input, test.want)
 			}
 
-			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(server{}), cmpopts.IgnoreUnexported(http.Server{}, http.ServeMux{}, slog.Logger{})); diff != "" {
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(server{}), cmpopts.IgnoreUnexported(http.Server{}, http.ServeMux{}, slog.Logger{}, probes.Probes{}, clientip.Resolver{}, metrics.Metrics{}, prometheus.Registry{}, levels.Controller{})); diff != "" {
 				t.Errorf("New(%v) = unexpected result (-want +got):\n%s\n", test.input, diff)
 			}
 		})
@@ -83,9 +109,11 @@ func TestServer_Start(t *testing.T) {
 			httpServer: &http.Server{
 				Addr: "localhost:8080",
 			},
+			router: http.NewServeMux(),
 			log: &mockLogger{
 				logs: &logs,
 			},
+			probes: probes.New(),
 		}
 		go func() {
 			time.Sleep(time.Millisecond * 100)
@@ -115,9 +143,11 @@ func TestServer_Start_Error(t *testing.T) {
 			httpServer: &http.Server{
 				Addr: "localhost:8080",
 			},
+			router: http.NewServeMux(),
 			log: &mockLogger{
 				logs: &logs,
 			},
+			probes: probes.New(),
 		}
 
 		httpServer := &http.Server{
@@ -146,6 +176,7 @@ func TestServer_Start_Error(t *testing.T) {
 }
 
 type mockLogger struct {
+	mu   sync.Mutex
 	logs *[]string
 }
 
@@ -161,6 +192,8 @@ func (l *mockLogger) Info(msg string, args ...any) {
 		}
 		messages = append(messages, val)
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	*l.logs = append(*l.logs, messages...)
 }
 
@@ -176,5 +209,31 @@ func (l *mockLogger) Error(msg string, args ...any) {
 		}
 		messages = append(messages, val)
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	*l.logs = append(*l.logs, messages...)
 }
+
+func (l *mockLogger) Debug(msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+func (l *mockLogger) Warn(msg string, args ...any) {
+	l.Error(msg, args...)
+}
+
+func (l *mockLogger) DebugContext(_ context.Context, msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+func (l *mockLogger) WarnContext(_ context.Context, msg string, args ...any) {
+	l.Error(msg, args...)
+}
+
+func (l *mockLogger) InfoContext(_ context.Context, msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+func (l *mockLogger) ErrorContext(_ context.Context, msg string, args ...any) {
+	l.Error(msg, args...)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/devcert"
+)
+
+// Defaults for TLS configuration.
+const (
+	defaultTLSMinVersion     = tls.VersionTLS12
+	defaultTLSReloadInterval = 10 * time.Second
+)
+
+// TLSOptions holds the TLS configuration for the server.
+type TLSOptions struct {
+	// CertFile and KeyFile are the paths to the PEM encoded certificate and key.
+	// They are reloaded when they change on disk.
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum TLS version, defaults to TLS 1.2.
+	MinVersion uint16
+	// CipherSuites are the cipher suites for TLS 1.2 and below, defaults to the
+	// cipher suites of crypto/tls.
+	CipherSuites []uint16
+	// ClientCAFile is the path to a PEM encoded CA bundle. When set, clients must
+	// present a certificate signed by one of the CAs (mutual TLS).
+	ClientCAFile string
+	// ReloadInterval is how often the certificate and key are checked for changes,
+	// defaults to 10 seconds.
+	ReloadInterval time.Duration
+	// Dev serves a self-signed development certificate for localhost, signed by a
+	// local CA, instead of CertFile and KeyFile. Only for local development.
+	Dev bool
+	// DevCertDir is the directory the development CA and certificate are cached in,
+	// defaults to .devcerts.
+	DevCertDir string
+}
+
+// tlsConfig returns the tls.Config of the server. In development mode the
+// development certificate is created if needed, and the path to its CA is logged.
+func (s server) tlsConfig() (*tls.Config, error) {
+	options := *s.tls
+	if options.Dev {
+		certs, err := devcert.Ensure(options.DevCertDir)
+		if err != nil {
+			return nil, err
+		}
+		options.CertFile, options.KeyFile = certs.CertFile, certs.KeyFile
+		caFile, err := filepath.Abs(certs.CAFile)
+		if err != nil {
+			caFile = certs.CAFile
+		}
+		s.log.Info("Using development certificate, trust the CA to avoid certificate warnings.", "caFile", caFile, "created", strconv.FormatBool(certs.Created))
+	}
+	return newTLSConfig(options, s.log)
+}
+
+// newTLSConfig returns a tls.Config for options, with the certificate
+// served by a certReloader.
+func newTLSConfig(options TLSOptions, log logger) (*tls.Config, error) {
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, errors.New("tls: both cert file and key file must be set")
+	}
+	if options.MinVersion == 0 {
+		options.MinVersion = defaultTLSMinVersion
+	}
+	if options.ReloadInterval == 0 {
+		options.ReloadInterval = defaultTLSReloadInterval
+	}
+
+	reloader, err := newCertReloader(options.CertFile, options.KeyFile, options.ReloadInterval, log)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     options.MinVersion,
+		CipherSuites:   options.CipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if options.ClientCAFile != "" {
+		b, err := os.ReadFile(options.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("tls: client CA: no certificates found in %s", options.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// certReloader serves a certificate and key pair from files, and reloads
+// them when their modification time changes.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	log      logger
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	checked  time.Time
+}
+
+// newCertReloader returns a certReloader with the certificate loaded.
+func newCertReloader(certFile, keyFile string, interval time.Duration, log logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		log:      log,
+	}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. At most once per interval the
+// files are checked for changes, and reloaded if they have changed. If a reload
+// fails the error is logged and the previous certificate is kept.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < r.interval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		r.log.Error("Failed to check certificate.", "error", err.Error())
+		return r.cert, nil
+	}
+	if modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+	if err := r.load(modTime); err != nil {
+		r.log.Error("Failed to reload certificate.", "error", err.Error())
+		return r.cert, nil
+	}
+	r.log.Info("Certificate reloaded.", "certFile", r.certFile)
+	return r.cert, nil
+}
+
+// load loads the certificate and key pair and records modTime.
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	return nil
+}
+
+// latestModTime returns the latest modification time of the certificate and key files.
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// ClientIdentity returns the identity of the client from its verified certificate:
+// the common name, or the first URI, DNS name or email address if it has none.
+// It returns false if the request has no verified client certificate.
+func ClientIdentity(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName, true
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String(), true
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0], true
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	stdlog "log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/http-server/internal/clientip"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", testCert{dnsNames: []string{"localhost"}})
+	caFile := ca.write(t, dir)
+
+	var tests = []struct {
+		name  string
+		input TLSOptions
+		want  struct {
+			minVersion uint16
+			clientAuth tls.ClientAuthType
+			err        string
+		}
+	}{
+		{
+			name:  "defaults",
+			input: TLSOptions{CertFile: certFile, KeyFile: keyFile},
+			want: struct {
+				minVersion uint16
+				clientAuth tls.ClientAuthType
+				err        string
+			}{minVersion: tls.VersionTLS12, clientAuth: tls.NoClientCert},
+		},
+		{
+			name:  "mutual TLS",
+			input: TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, MinVersion: tls.VersionTLS13},
+			want: struct {
+				minVersion uint16
+				clientAuth tls.ClientAuthType
+				err        string
+			}{minVersion: tls.VersionTLS13, clientAuth: tls.RequireAndVerifyClientCert},
+		},
+		{
+			name:  "missing key file",
+			input: TLSOptions{CertFile: certFile},
+			want: struct {
+				minVersion uint16
+				clientAuth tls.ClientAuthType
+				err        string
+			}{err: "tls: both cert file and key file must be set"},
+		},
+		{
+			name:  "invalid client CA",
+			input: TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile},
+			want: struct {
+				minVersion uint16
+				clientAuth tls.ClientAuthType
+				err        string
+			}{err: "tls: client CA: no certificates found in " + keyFile},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := newTLSConfig(test.input, &mockLogger{logs: &[]string{}})
+			if err != nil {
+				if diff := cmp.Diff(test.want.err, err.Error()); diff != "" {
+					t.Errorf("newTLSConfig() = unexpected error (-want +got):\n%s\n", diff)
+				}
+				return
+			}
+			if test.want.err != "" {
+				t.Fatalf("newTLSConfig() = nil; want error %q", test.want.err)
+			}
+			if got.MinVersion != test.want.minVersion {
+				t.Errorf("MinVersion = %x; want %x", got.MinVersion, test.want.minVersion)
+			}
+			if got.ClientAuth != test.want.clientAuth {
+				t.Errorf("ClientAuth = %v; want %v", got.ClientAuth, test.want.clientAuth)
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", testCert{commonName: "first"})
+
+	logs := []string{}
+	r, err := newCertReloader(certFile, keyFile, time.Nanosecond, &mockLogger{logs: &logs})
+	if err != nil {
+		t.Fatalf("newCertReloader() = unexpected error: %v", err)
+	}
+	if got := leafCommonName(t, r); got != "first" {
+		t.Errorf("GetCertificate() = %q; want %q", got, "first")
+	}
+
+	ca.issue(t, dir, "server", testCert{commonName: "second"})
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := leafCommonName(t, r); got != "second" {
+		t.Errorf("GetCertificate() = %q; want %q", got, "second")
+	}
+
+	if err := os.WriteFile(certFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	evenLater := later.Add(time.Minute)
+	if err := os.Chtimes(certFile, evenLater, evenLater); err != nil {
+		t.Fatal(err)
+	}
+	if got := leafCommonName(t, r); got != "second" {
+		t.Errorf("GetCertificate() = %q; want %q", got, "second")
+	}
+
+	want := []string{"Certificate reloaded.", "certFile", certFile, "Failed to reload certificate.", "error"}
+	if diff := cmp.Diff(want, logs[:len(want)]); diff != "" {
+		t.Errorf("GetCertificate() = unexpected logs (-want +got):\n%s\n", diff)
+	}
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", testCert{ips: []net.IP{net.ParseIP("127.0.0.1")}})
+	caFile := ca.write(t, dir)
+
+	var tests = []struct {
+		name  string
+		input testCert
+		want  struct {
+			body string
+			logs []string
+			err  bool
+		}
+	}{
+		{
+			name:  "client identity from common name",
+			input: testCert{commonName: "client-1", client: true},
+			want: struct {
+				body string
+				logs []string
+				err  bool
+			}{
+				body: "client-1",
+				logs: []string{"Request received.", "status", "200", "path", "/", "method", "GET", "remoteIp", "127.0.0.1", "clientId", "client-1"},
+			},
+		},
+		{
+			name:  "client identity from URI",
+			input: testCert{uri: "spiffe://example.org/worker", client: true},
+			want: struct {
+				body string
+				logs []string
+				err  bool
+			}{
+				body: "spiffe://example.org/worker",
+				logs: []string{"Request received.", "status", "200", "path", "/", "method", "GET", "remoteIp", "127.0.0.1", "clientId", "spiffe://example.org/worker"},
+			},
+		},
+		{
+			name: "no client certificate",
+			want: struct {
+				body string
+				logs []string
+				err  bool
+			}{err: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs := []string{}
+			log := &mockLogger{logs: &logs}
+			cfg, err := newTLSConfig(TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, log)
+			if err != nil {
+				t.Fatalf("newTLSConfig() = unexpected error: %v", err)
+			}
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := &http.Server{
+				Handler: requestLogger(log, &clientip.Resolver{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					id, _ := ClientIdentity(r)
+					w.Write([]byte(id))
+				})),
+				TLSConfig: cfg,
+				ErrorLog:  stdlog.New(io.Discard, "", 0),
+			}
+			go srv.ServeTLS(ln, "", "")
+			defer srv.Close()
+
+			clientCfg := &tls.Config{RootCAs: ca.pool()}
+			if test.input.client {
+				clientCert, clientKey := ca.issue(t, t.TempDir(), "client", test.input)
+				cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				clientCfg.Certificates = []tls.Certificate{cert}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientCfg}}
+
+			resp, err := client.Get("https://" + ln.Addr().String() + "/")
+			if test.want.err {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("Get() = nil; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() = unexpected error: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if diff := cmp.Diff(test.want.body, string(body)); diff != "" {
+				t.Errorf("Get() = unexpected body (-want +got):\n%s\n", diff)
+			}
+			if diff := cmp.Diff(test.want.logs, logs); diff != "" {
+				t.Errorf("requestLogger() = unexpected logs (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+// testCA is a certificate authority for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// testCert describes a certificate issued by a testCA.
+type testCert struct {
+	commonName string
+	dnsNames   []string
+	ips        []net.IP
+	uri        string
+	client     bool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue issues a certificate and writes it and its key to <name>.crt and <name>.key in dir.
+func (ca *testCA) issue(t *testing.T, dir, name string, c testCert) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: c.commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     c.dnsNames,
+		IPAddresses:  c.ips,
+	}
+	if c.client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	if c.uri != "" {
+		u, err := url.Parse(c.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+// write writes the certificate of the CA to ca.crt in dir.
+func (ca *testCA) write(t *testing.T, dir string) string {
+	t.Helper()
+	file := filepath.Join(dir, "ca.crt")
+	writePEM(t, file, "CERTIFICATE", ca.cert.Raw)
+	return file
+}
+
+// pool returns a cert pool with the certificate of the CA.
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func writePEM(t *testing.T, file, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// leafCommonName returns the common name of the certificate served by r.
+func leafCommonName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestServer_DevTLS(t *testing.T) {
+	dir := t.TempDir()
+	logs := []string{}
+	srv := NewServer(WithOptions(Options{
+		Log: &mockLogger{
+			logs: &logs,
+		},
+		TLS: &TLSOptions{Dev: true, DevCertDir: dir},
+	}))
+
+	cfg, err := srv.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig() = unexpected error: %v", err)
+	}
+
+	want := []string{"Using development certificate, trust the CA to avoid certificate warnings.", "caFile", filepath.Join(dir, "ca.crt"), "created", "true"}
+	if diff := cmp.Diff(want, logs); diff != "" {
+		t.Errorf("tlsConfig() = unexpected logs (-want +got):\n%s\n", diff)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig: cfg,
+	}
+	go httpServer.ServeTLS(ln, "", "")
+	defer httpServer.Close()
+
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Get() = unexpected error: %v", err)
+	}
+	resp.Body.Close()
+}
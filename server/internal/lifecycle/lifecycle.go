@@ -0,0 +1,126 @@
+// Package lifecycle starts the components of a process in order and stops them in
+// reverse order.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Component is a part of a process with a lifecycle managed by a Supervisor.
+//
+// Start must return once the component is ready, or with an error if it could
+// not be started. Long running work continues in goroutines until Stop is called.
+// Stop must return once the component has stopped or ctx is done.
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Failer is implemented by components that can fail after they have started.
+// The Supervisor reports the first error received on the returned channel on Failed.
+type Failer interface {
+	Err() <-chan error
+}
+
+// Namer is implemented by components that want to be identified by name
+// in errors and logs.
+type Namer interface {
+	Name() string
+}
+
+// componentName returns the name of c, or its type if it does not implement Namer.
+func componentName(c Component) string {
+	if n, ok := c.(Namer); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+// Supervisor starts components in order and stops them in reverse order.
+type Supervisor struct {
+	components  []Component
+	stopTimeout time.Duration
+	started     int
+	failed      chan error
+	once        sync.Once
+}
+
+// NewSupervisor returns a Supervisor for components where each component
+// gets stopTimeout to stop.
+func NewSupervisor(components []Component, stopTimeout time.Duration) *Supervisor {
+	return &Supervisor{
+		components:  components,
+		stopTimeout: stopTimeout,
+		failed:      make(chan error, 1),
+	}
+}
+
+// Start starts the components in order. If a component fails to start, the
+// components already started are stopped and all errors are returned joined.
+func (sv *Supervisor) Start(ctx context.Context) error {
+	for _, c := range sv.components {
+		if err := c.Start(ctx); err != nil {
+			err = fmt.Errorf("start %s: %w", componentName(c), err)
+			return errors.Join(err, sv.Stop())
+		}
+		sv.started++
+		if f, ok := c.(Failer); ok && f.Err() != nil {
+			go sv.watch(c, f.Err())
+		}
+	}
+	return nil
+}
+
+// Failed returns a channel that receives the first error of a Failer, wrapped with
+// the name of its component.
+func (sv *Supervisor) Failed() <-chan error {
+	return sv.failed
+}
+
+// watch forwards the first error received on errCh to the failed channel.
+func (sv *Supervisor) watch(c Component, errCh <-chan error) {
+	err, ok := <-errCh
+	if !ok || err == nil {
+		return
+	}
+	sv.once.Do(func() {
+		sv.failed <- fmt.Errorf("%s: %w", componentName(c), err)
+	})
+}
+
+// Stop stops the started components in reverse order, giving each one
+// stopTimeout, and returns all errors joined.
+func (sv *Supervisor) Stop() error {
+	var errs []error
+	for i := sv.started - 1; i >= 0; i-- {
+		c := sv.components[i]
+		if err := sv.stopComponent(c); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", componentName(c), err))
+		}
+	}
+	sv.started = 0
+	return errors.Join(errs...)
+}
+
+// stopComponent stops c, and gives up once the stop timeout has passed even
+// if c does not return.
+func (sv *Supervisor) stopComponent(c Component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sv.stopTimeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Stop(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Environment variables used to hand off listeners to a new process on restart.
+const (
+	envHandoffListeners = "HANDOFF_LISTENERS"
+	envHandoffReadyFD   = "HANDOFF_READY_FD"
+)
+
+// DefaultRestartTimeout is how long a restart waits for the new process to be ready.
+const DefaultRestartTimeout = 30 * time.Second
+
+// Restart starts a new process of the executable with the same arguments, hands off
+// listeners to it and waits up to timeout for it to be ready. names are the names of
+// the listeners, the new process reuses a listener instead of listening on an address
+// with the same name. Both processes accept connections until this one shuts down.
+// It returns the pid of the new process.
+func Restart(listeners []net.Listener, names []string, timeout time.Duration) (int, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("restart: %w", err)
+	}
+	// The executable has been replaced if a new binary was deployed.
+	path = strings.TrimSuffix(path, " (deleted)")
+
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range listeners {
+		f, err := handoffFile(ln)
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, f)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("restart: %w", err)
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		envHandoffListeners+"="+strings.Join(names, "\n"),
+		envHandoffReadyFD+"="+strconv.Itoa(listenFdsStart+len(listeners)),
+	)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("restart: %w", err)
+	}
+	// Only the new process holds the write end, reading fails if it exits.
+	readyW.Close()
+
+	ready := make(chan error, 1)
+	go func() {
+		if _, err := readyR.Read(make([]byte, 1)); err != nil {
+			ready <- errors.New("restart: new process exited before it was ready")
+			return
+		}
+		ready <- nil
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(timeout):
+		err = errors.New("restart: timed out waiting for new process to be ready")
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return 0, err
+	}
+
+	// The sockets are now owned by the new process, they must not be removed
+	// when this one shuts down.
+	for _, ln := range listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	return pid, nil
+}
+
+// handoffFile returns a duplicate of the file descriptor of ln to hand off to a new
+// process. The File method of a listener is not used: exec.Cmd puts the file it
+// returns in blocking mode, and as both file descriptors share the flags of the
+// socket, an accept of this process would then block until the next connection,
+// delaying its shutdown and taking the connection from the new process.
+func handoffFile(ln net.Listener) (*os.File, error) {
+	sc, ok := ln.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("restart: listener %s cannot be handed off", ln.Addr())
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, fmt.Errorf("restart: %w", err)
+	}
+	var fd int
+	var dupErr error
+	err = rc.Control(func(s uintptr) {
+		syscall.ForkLock.RLock()
+		defer syscall.ForkLock.RUnlock()
+		if fd, dupErr = syscall.Dup(int(s)); dupErr == nil {
+			syscall.CloseOnExec(fd)
+		}
+	})
+	if err == nil {
+		err = dupErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restart: %w", err)
+	}
+	return os.NewFile(uintptr(fd), ln.Addr().String()), nil
+}
+
+// inheritHandoff returns listeners for the file descriptors handed off by the previous
+// process, starting at the file descriptor start. names are the names of the listeners
+// separated by newlines.
+func inheritHandoff(names string, start int) ([]net.Listener, []string, error) {
+	if names == "" {
+		return nil, nil, nil
+	}
+	listenerNames := strings.Split(names, "\n")
+
+	var listeners []net.Listener
+	for i, name := range listenerNames {
+		fd := start + i
+		syscall.CloseOnExec(fd)
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, nil, fmt.Errorf("restart: listener %s: %w", name, err)
+		}
+		// Remove the socket on shutdown as if this process had created it.
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(true)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, listenerNames, nil
+}
+
+// NotifyReady tells the previous process that handed off its listeners that this
+// process is serving. It does nothing if the process was not started by a restart.
+func NotifyReady() error {
+	fd := os.Getenv(envHandoffReadyFD)
+	if fd == "" {
+		return nil
+	}
+	os.Unsetenv(envHandoffReadyFD)
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return fmt.Errorf("restart: invalid %s %q", envHandoffReadyFD, fd)
+	}
+	f := os.NewFile(uintptr(n), "ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package listener
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInheritHandoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, names, err := inheritHandoff("tcp:"+ln.Addr().String(), int(f.Fd()))
+	if err != nil {
+		t.Fatalf("inheritHandoff() = unexpected error: %v", err)
+	}
+	defer got[0].Close()
+	if diff := cmp.Diff([]string{"tcp:" + ln.Addr().String()}, names); diff != "" {
+		t.Errorf("inheritHandoff() = unexpected names (-want +got):\n%s\n", diff)
+	}
+	if got[0].Addr().String() != ln.Addr().String() {
+		t.Errorf("Addr() = %s; want %s", got[0].Addr(), ln.Addr())
+	}
+
+	got, names, err = inheritHandoff("", int(f.Fd()))
+	if err != nil || got != nil || names != nil {
+		t.Errorf("inheritHandoff() = %v, %v, %v; want nil, nil, nil", got, names, err)
+	}
+}
+
+func TestHandoffFile(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f, err := handoffFile(ln)
+	if err != nil {
+		t.Fatalf("handoffFile() = unexpected error: %v", err)
+	}
+	defer f.Close()
+	// exec.Cmd passes the Fd of its ExtraFiles to the new process.
+	f.Fd()
+
+	rc, err := ln.(*net.TCPListener).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flags uintptr
+	rc.Control(func(fd uintptr) {
+		flags, _, _ = syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+	})
+	if flags&syscall.O_NONBLOCK == 0 {
+		t.Errorf("listener is in blocking mode after its handoff file was passed to a new process")
+	}
+}
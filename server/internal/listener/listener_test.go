@@ -0,0 +1,217 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListen(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "stale.sock")
+	staleLn, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staleLn.(*net.UnixListener).SetUnlinkOnClose(false)
+	staleLn.Close()
+
+	active := filepath.Join(dir, "active.sock")
+	activeLn, err := net.Listen("unix", active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer activeLn.Close()
+
+	var tests = []struct {
+		name  string
+		input Config
+		want  struct {
+			mode os.FileMode
+			err  string
+		}
+	}{
+		{
+			name:  "tcp",
+			input: Config{Network: NetworkTCP, Address: "127.0.0.1:0"},
+		},
+		{
+			name:  "unix with mode",
+			input: Config{Network: NetworkUnix, Address: filepath.Join(dir, "server.sock"), Mode: 0o660},
+			want: struct {
+				mode os.FileMode
+				err  string
+			}{mode: 0o660},
+		},
+		{
+			name:  "unix replaces stale socket",
+			input: Config{Network: NetworkUnix, Address: stale, Mode: 0o600},
+			want: struct {
+				mode os.FileMode
+				err  string
+			}{mode: 0o600},
+		},
+		{
+			name:  "unix socket in use",
+			input: Config{Network: NetworkUnix, Address: active},
+			want: struct {
+				mode os.FileMode
+				err  string
+			}{err: "listen unix " + active + ": address already in use"},
+		},
+		{
+			name:  "unsupported network",
+			input: Config{Network: "udp", Address: ":53"},
+			want: struct {
+				mode os.FileMode
+				err  string
+			}{err: `listen udp::53: unsupported network "udp"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Listen(test.input)
+			if err != nil {
+				if diff := cmp.Diff(test.want.err, err.Error()); diff != "" {
+					t.Errorf("Listen() = unexpected error (-want +got):\n%s\n", diff)
+				}
+				return
+			}
+			defer got[0].Close()
+			if test.want.err != "" {
+				t.Fatalf("Listen() = nil; want error %q", test.want.err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("Listen() = %d listeners; want 1", len(got))
+			}
+
+			if test.input.Network == NetworkUnix {
+				fi, err := os.Stat(test.input.Address)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm() != test.want.mode {
+					t.Errorf("mode = %v; want %v", fi.Mode().Perm(), test.want.mode)
+				}
+			}
+		})
+	}
+}
+
+func TestInheritListeners(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := int(f.Fd())
+
+	t.Run("other process", func(t *testing.T) {
+		got, _, err := inheritListeners(strconv.Itoa(os.Getpid()+1), "1", "web", fd)
+		if err != nil || got != nil {
+			t.Errorf("inheritListeners() = %v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("invalid LISTEN_FDS", func(t *testing.T) {
+		_, _, err := inheritListeners(strconv.Itoa(os.Getpid()), "x", "", fd)
+		if diff := cmp.Diff(`systemd: invalid LISTEN_FDS "x"`, errString(err)); diff != "" {
+			t.Errorf("inheritListeners() = unexpected error (-want +got):\n%s\n", diff)
+		}
+	})
+
+	t.Run("named socket", func(t *testing.T) {
+		got, names, err := inheritListeners(strconv.Itoa(os.Getpid()), "1", "web", fd)
+		if err != nil {
+			t.Fatalf("inheritListeners() = unexpected error: %v", err)
+		}
+		defer got[0].Close()
+		if diff := cmp.Diff([]string{"web"}, names); diff != "" {
+			t.Errorf("inheritListeners() = unexpected names (-want +got):\n%s\n", diff)
+		}
+		if got[0].Addr().String() != ln.Addr().String() {
+			t.Errorf("Addr() = %s; want %s", got[0].Addr(), ln.Addr())
+		}
+	})
+}
+
+func TestInheritedListeners_Claim(t *testing.T) {
+	var listeners []net.Listener
+	for i := 0; i < 3; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		listeners = append(listeners, ln)
+	}
+	l := &inheritedListeners{listeners: append([]net.Listener(nil), listeners...), names: []string{"web", "admin", "web"}}
+	l.once.Do(func() {})
+
+	var tests = []struct {
+		name  string
+		input string
+		want  struct {
+			listeners []net.Listener
+			err       string
+		}
+	}{
+		{
+			name:  "by name",
+			input: "web",
+			want: struct {
+				listeners []net.Listener
+				err       string
+			}{listeners: []net.Listener{listeners[0], listeners[2]}},
+		},
+		{
+			name:  "already claimed",
+			input: "web",
+			want: struct {
+				listeners []net.Listener
+				err       string
+			}{err: "listen systemd:web: no inherited socket"},
+		},
+		{
+			name:  "all unclaimed",
+			input: "",
+			want: struct {
+				listeners []net.Listener
+				err       string
+			}{listeners: []net.Listener{listeners[1]}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := l.claim(test.input)
+			if diff := cmp.Diff(test.want.err, errString(err)); diff != "" {
+				t.Errorf("claim() = unexpected error (-want +got):\n%s\n", diff)
+			}
+			if len(got) != len(test.want.listeners) {
+				t.Fatalf("claim() = %d listeners; want %d", len(got), len(test.want.listeners))
+			}
+			for i := range got {
+				if got[i] != test.want.listeners[i] {
+					t.Errorf("claim()[%d] = %s; want %s", i, got[i].Addr(), test.want.listeners[i].Addr())
+				}
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
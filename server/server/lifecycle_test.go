@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// The failure handling of the supervisor is tested by the lifecycle package, this
+// tests that the server starts, stops and logs its components.
+func TestServer_Start_Components(t *testing.T) {
+	logs := []string{}
+	events := &eventLog{}
+	failing := &mockComponent{name: "worker", events: events, err: make(chan error, 1)}
+	srv := New(WithOptions(Options{
+		Log: &mockLogger{
+			logs: &logs,
+		},
+		Components: []Component{
+			&mockComponent{name: "db", events: events},
+			failing,
+		},
+	}))
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		failing.err <- errors.New("crashed")
+	}()
+	if err := srv.Start(); err == nil || err.Error() != "worker: crashed" {
+		t.Errorf("Start() = unexpected error: %v", err)
+	}
+
+	want := []string{"start db", "start worker", "stop worker", "stop db"}
+	if diff := cmp.Diff(want, events.get()); diff != "" {
+		t.Errorf("Start() = unexpected events (-want +got):\n%s\n", diff)
+	}
+	wantLogs := []string{
+		"Server started.",
+		"Failed to shutdown server gracefully.",
+		"reason",
+		"component failed",
+		"error",
+		"worker: crashed",
+	}
+	if diff := cmp.Diff(wantLogs, logs); diff != "" {
+		t.Errorf("Start() = unexpected logs (-want +got):\n%s\n", diff)
+	}
+}
+
+// eventLog records the lifecycle events of mock components.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.events
+}
+
+type mockComponent struct {
+	name   string
+	events *eventLog
+	err    chan error
+}
+
+func (c *mockComponent) Name() string {
+	return c.name
+}
+
+func (c *mockComponent) Start(ctx context.Context) error {
+	c.events.add("start " + c.name)
+	return nil
+}
+
+func (c *mockComponent) Stop(ctx context.Context) error {
+	c.events.add("stop " + c.name)
+	return nil
+}
+
+func (c *mockComponent) Err() <-chan error {
+	return c.err
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/server/internal/lifecycle"
+	"github.com/Zate/go-templates/server/internal/listener"
+)
+
+// listenerComponent is a component that accepts connections on the listeners of
+// configs and answers each with "ok".
+type listenerComponent struct {
+	configs   []listener.Config
+	listeners []net.Listener
+}
+
+func (c *listenerComponent) Start(ctx context.Context) error {
+	listeners, err := listener.ListenAll(c.configs)
+	if err != nil {
+		return err
+	}
+	c.listeners = listeners
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Write([]byte("ok"))
+				conn.Close()
+			}
+		}(ln)
+	}
+	return nil
+}
+
+func (c *listenerComponent) Stop(ctx context.Context) error {
+	for _, ln := range c.listeners {
+		ln.Close()
+	}
+	return nil
+}
+
+func TestServer_Listeners(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "server.sock")
+	c := &listenerComponent{configs: []listener.Config{
+		{Network: listener.NetworkTCP, Address: "127.0.0.1:0"},
+		{Network: listener.NetworkUnix, Address: socket, Mode: 0o600},
+	}}
+	sv := lifecycle.NewSupervisor([]Component{c}, time.Second)
+	if err := sv.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = unexpected error: %v", err)
+	}
+
+	for _, ln := range c.listeners {
+		conn, err := net.Dial(ln.Addr().Network(), ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial(%s) = unexpected error: %v", ln.Addr(), err)
+		}
+		got, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil || string(got) != "ok" {
+			t.Errorf("Dial(%s) = %q, %v; want ok", ln.Addr(), got, err)
+		}
+	}
+	if fi, err := os.Stat(socket); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket = %v, %v; want mode 0600", fi, err)
+	}
+
+	if err := sv.Stop(); err != nil {
+		t.Fatalf("Stop() = unexpected error: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket should be removed on stop, got %v", err)
+	}
+}
@@ -1,17 +1,24 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"os"
+
+	"github.com/Zate/go-templates/server/internal/logctx"
 )
 
-// logger is the interface that wraps around methods Info and Error.
+// logger is the interface that wraps around methods Info and Error, and their
+// variants that log with the request ID and trace ID stored in a context.
 type logger interface {
 	Info(msg string, args ...any)
 	Error(msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
 }
 
-// NewDefaultLogger creates a new default logger.
+// NewDefaultLogger creates a new default logger, that adds the values of the
+// context of a record with a logctx.Handler.
 func NewDefaultLogger() logger {
-	return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	return slog.New(logctx.NewHandler(slog.NewJSONHandler(os.Stderr, nil), logctx.DefaultKeys))
 }
@@ -1,20 +1,47 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/Zate/go-templates/server/internal/lifecycle"
+)
+
+// Defaults for server configuration.
+const (
+	defaultStopTimeout = 15 * time.Second
 )
 
-// server ...
+// Component is a part of the server with a lifecycle managed by the server, see
+// lifecycle.Component.
+type Component = lifecycle.Component
+
+// Failer is implemented by components that can fail after they have started.
+// The server shuts down when an error is received on the returned channel.
+type Failer = lifecycle.Failer
+
+// Namer is implemented by components that want to be identified by name
+// in errors and logs.
+type Namer = lifecycle.Namer
+
+// server starts and stops its components.
 type server struct {
-	log logger
+	log         logger
+	components  []Component
+	stopTimeout time.Duration
 }
 
 // Options holds the configuration for the server.
 type Options struct {
 	Log logger
+	// Components are started in order and stopped in reverse order.
+	Components []Component
+	// StopTimeout is the time each component gets to stop.
+	StopTimeout time.Duration
 }
 
 // Option is a function that configures the server.
@@ -30,44 +57,53 @@ func New(options ...Option) *server {
 	if s.log == nil {
 		s.log = NewDefaultLogger()
 	}
+	if s.stopTimeout == 0 {
+		s.stopTimeout = defaultStopTimeout
+	}
 
 	return s
 }
 
-// Start the server.
+// Start the server. It starts the components in order and blocks until the
+// process receives a signal or a component fails, and then stops them.
 func (s server) Start() error {
-	errCh := make(chan error, 1)
-	go func() {
-		// Add server startup code here.
-		// Send errors to errCh.
-	}()
+	sv := lifecycle.NewSupervisor(s.components, s.stopTimeout)
 
-	select {
-	case err := <-errCh:
-		s.log.Error("Failed to start server.")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := sv.Start(ctx); err != nil {
+		s.log.Error("Failed to start server.", "error", err.Error())
 		return err
-	case <-time.After(10 * time.Millisecond):
-		// Code for when server start is finsihed.
 	}
+	s.log.Info("Server started.")
 
-	sig, err := s.shutdown()
+	reason, err := s.shutdown(sv)
 	if err != nil {
-		s.log.Error("Failed to shutdown server gracefully.")
+		s.log.Error("Failed to shutdown server gracefully.", "reason", reason, "error", err.Error())
 		return err
 	}
-	s.log.Info("Server shutdown.", "reason", sig.String())
+	s.log.Info("Server shutdown.", "reason", reason)
 	return nil
 }
 
-// shutdown the server.
-func (s server) shutdown() (os.Signal, error) {
+// shutdown the server. It waits for a signal or a component failure, stops
+// the components and returns the reason for the shutdown.
+func (s server) shutdown(sv *lifecycle.Supervisor) (string, error) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-stop
+	defer signal.Stop(stop)
 
-	// Add server shutdown logic here.
+	var reason string
+	var failErr error
+	select {
+	case sig := <-stop:
+		reason = sig.String()
+	case failErr = <-sv.Failed():
+		reason = "component failed"
+	}
 
-	return sig, nil
+	return reason, errors.Join(failErr, sv.Stop())
 }
 
 // WithOptions configures the server with the given Options.
@@ -75,5 +111,7 @@ func WithOptions(options Options) Option {
 	return func(s *server) {
 		// Setup on all options from the option struct here.
 		s.log = options.Log
+		s.components = options.Components
+		s.stopTimeout = options.StopTimeout
 	}
 }
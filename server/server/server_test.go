@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"syscall"
 	"testing"
@@ -20,7 +21,8 @@ func TestNew(t *testing.T) {
 			name:  "default",
 			input: []Option{},
 			want: &server{
-				log: NewDefaultLogger(),
+				log:         NewDefaultLogger(),
+				stopTimeout: defaultStopTimeout,
 			},
 		},
 		{
@@ -31,7 +33,8 @@ func TestNew(t *testing.T) {
 				}),
 			},
 			want: &server{
-				log: NewDefaultLogger(),
+				log:         NewDefaultLogger(),
+				stopTimeout: defaultStopTimeout,
 			},
 		},
 	}
@@ -65,6 +68,7 @@ func TestServer_Start(t *testing.T) {
 		srv.Start()
 
 		want := []string{
+			"Server started.",
 			"Server shutdown.",
 			"reason",
 			"interrupt",
@@ -95,3 +99,11 @@ func (l *mockLogger) Error(msg string, args ...any) {
 	}
 	*l.logs = append(*l.logs, messages...)
 }
+
+func (l *mockLogger) InfoContext(_ context.Context, msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+func (l *mockLogger) ErrorContext(_ context.Context, msg string, args ...any) {
+	l.Error(msg, args...)
+}
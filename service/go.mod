@@ -1,6 +1,6 @@
 module github.com/Zate/go-templates/service
 
-go 1.21.4
+go 1.22
 
 require (
 	github.com/RedeployAB/go-template/templates/service v0.0.0-20230925171834-c8892605c3ac
@@ -0,0 +1,191 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSupervisor(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			components func(events *eventLog) []Component
+		}
+		want struct {
+			startErr string
+			stopErr  string
+			events   []string
+		}
+	}{
+		{
+			name: "start in order and stop in reverse order",
+			input: struct {
+				components func(events *eventLog) []Component
+			}{
+				components: func(events *eventLog) []Component {
+					return []Component{
+						&mockComponent{name: "db", events: events},
+						&mockComponent{name: "cache", events: events},
+						&mockComponent{name: "http", events: events},
+					}
+				},
+			},
+			want: struct {
+				startErr string
+				stopErr  string
+				events   []string
+			}{
+				events: []string{"start db", "start cache", "start http", "stop http", "stop cache", "stop db"},
+			},
+		},
+		{
+			name: "failed start stops started components",
+			input: struct {
+				components func(events *eventLog) []Component
+			}{
+				components: func(events *eventLog) []Component {
+					return []Component{
+						&mockComponent{name: "db", events: events, stopErr: errors.New("db busy")},
+						&mockComponent{name: "cache", events: events, startErr: errors.New("connection refused")},
+						&mockComponent{name: "http", events: events},
+					}
+				},
+			},
+			want: struct {
+				startErr string
+				stopErr  string
+				events   []string
+			}{
+				startErr: "start cache: connection refused\nstop db: db busy",
+				events:   []string{"start db", "start cache", "stop db"},
+			},
+		},
+		{
+			name: "stop errors are aggregated",
+			input: struct {
+				components func(events *eventLog) []Component
+			}{
+				components: func(events *eventLog) []Component {
+					return []Component{
+						&mockComponent{name: "db", events: events, stopErr: errors.New("db busy")},
+						&mockComponent{name: "http", events: events, stopDelay: time.Second},
+					}
+				},
+			},
+			want: struct {
+				startErr string
+				stopErr  string
+				events   []string
+			}{
+				stopErr: "stop http: context deadline exceeded\nstop db: db busy",
+				events:  []string{"start db", "start http", "stop db"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := &eventLog{}
+			sv := NewSupervisor(test.input.components(events), 50*time.Millisecond)
+
+			gotStartErr := errString(sv.Start(context.Background()))
+			if diff := cmp.Diff(test.want.startErr, gotStartErr); diff != "" {
+				t.Errorf("Start() = unexpected error (-want +got):\n%s\n", diff)
+			}
+			if gotStartErr == "" {
+				gotStopErr := errString(sv.Stop())
+				if diff := cmp.Diff(test.want.stopErr, gotStopErr); diff != "" {
+					t.Errorf("Stop() = unexpected error (-want +got):\n%s\n", diff)
+				}
+			}
+
+			if diff := cmp.Diff(test.want.events, events.get()); diff != "" {
+				t.Errorf("Supervisor = unexpected events (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestSupervisor_Failed(t *testing.T) {
+	events := &eventLog{}
+	failing := &mockComponent{name: "worker", events: events, err: make(chan error, 2)}
+	sv := NewSupervisor([]Component{failing}, 50*time.Millisecond)
+	if err := sv.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = unexpected error: %v", err)
+	}
+	failing.err <- errors.New("crashed")
+	failing.err <- errors.New("crashed again")
+
+	select {
+	case err := <-sv.Failed():
+		if diff := cmp.Diff("worker: crashed", errString(err)); diff != "" {
+			t.Errorf("Failed() = unexpected error (-want +got):\n%s\n", diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Failed() = no error")
+	}
+	if err := sv.Stop(); err != nil {
+		t.Errorf("Stop() = unexpected error: %v", err)
+	}
+}
+
+// eventLog records the lifecycle events of mock components.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.events
+}
+
+type mockComponent struct {
+	name      string
+	events    *eventLog
+	startErr  error
+	stopErr   error
+	stopDelay time.Duration
+	err       chan error
+}
+
+func (c *mockComponent) Name() string {
+	return c.name
+}
+
+func (c *mockComponent) Start(ctx context.Context) error {
+	c.events.add("start " + c.name)
+	return c.startErr
+}
+
+func (c *mockComponent) Stop(ctx context.Context) error {
+	if c.stopDelay > 0 {
+		time.Sleep(c.stopDelay)
+		return nil
+	}
+	c.events.add("stop " + c.name)
+	return c.stopErr
+}
+
+func (c *mockComponent) Err() <-chan error {
+	return c.err
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
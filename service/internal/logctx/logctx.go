@@ -0,0 +1,143 @@
+// Package logctx stores log values in a context.Context, and adds them to the records
+// logged with it, so that records logged with the context of a request can be
+// correlated with the request.
+package logctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// Keys are the keys of the attributes added by a Handler.
+type Keys struct {
+	RequestID string
+	TraceID   string
+	SpanID    string
+}
+
+// DefaultKeys are the keys of the attributes added by a Handler of the template.
+var DefaultKeys = Keys{RequestID: "requestId", TraceID: "traceId", SpanID: "spanId"}
+
+// contextKey is the type of the keys of the log values stored in a context.Context.
+type contextKey int
+
+const (
+	requestIDContextKey contextKey = iota
+	traceContextKey
+	attrsContextKey
+)
+
+// traceIDs are the IDs of the trace and span a context belongs to.
+type traceIDs struct {
+	traceID string
+	spanID  string
+}
+
+// WithRequestID returns a copy of ctx with the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, id)
+}
+
+// RequestID returns the request ID of ctx, or an empty string if it has none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// WithTraceIDs returns a copy of ctx with the IDs of the trace and span it belongs to.
+// An empty spanID is not logged.
+func WithTraceIDs(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceContextKey, traceIDs{traceID: traceID, spanID: spanID})
+}
+
+// TraceIDs returns the IDs of the trace and span of ctx, or empty strings if it has none.
+func TraceIDs(ctx context.Context) (traceID, spanID string) {
+	ids, _ := ctx.Value(traceContextKey).(traceIDs)
+	return ids.traceID, ids.spanID
+}
+
+// WithAttrs returns a copy of ctx with attrs added to the attributes logged with it.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsContextKey).([]slog.Attr)
+	return context.WithValue(ctx, attrsContextKey, append(slices.Clip(existing), attrs...))
+}
+
+// Extractor returns attributes to log from values of a context, e.g. the ID of an
+// authenticated user stored by a middleware.
+type Extractor func(ctx context.Context) []slog.Attr
+
+// Handler is a slog.Handler that adds the request ID, the trace and span IDs and the
+// attributes stored in the context of a record to it. Attributes of the record take
+// precedence over those of the context.
+type Handler struct {
+	handler    slog.Handler
+	keys       Keys
+	extractors []Extractor
+}
+
+// NewHandler returns a Handler that passes records to handler, with the values of
+// their context logged with keys, and the attributes returned by extractors added
+// as well.
+func NewHandler(handler slog.Handler, keys Keys, extractors ...Extractor) *Handler {
+	return &Handler{handler: handler, keys: keys, extractors: extractors}
+}
+
+// Enabled reports whether the handler it wraps handles records at level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the attributes of ctx to r and passes it to the handler it wraps.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := h.contextAttrs(ctx)
+	for _, extract := range h.extractors {
+		attrs = append(attrs, extract(ctx)...)
+	}
+	if len(attrs) > 0 {
+		keys := map[string]bool{}
+		r.Attrs(func(a slog.Attr) bool {
+			keys[a.Key] = true
+			return true
+		})
+		r = r.Clone()
+		for _, a := range attrs {
+			if !keys[a.Key] {
+				r.AddAttrs(a)
+			}
+		}
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a Handler wrapping the handler with attrs.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{handler: h.handler.WithAttrs(attrs), keys: h.keys, extractors: h.extractors}
+}
+
+// WithGroup returns a Handler wrapping the handler with the group name. The attributes
+// of the context are added to the group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{handler: h.handler.WithGroup(name), keys: h.keys, extractors: h.extractors}
+}
+
+// contextAttrs returns the request ID, trace and span IDs and attributes stored in ctx.
+func (h *Handler) contextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	var attrs []slog.Attr
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String(h.keys.RequestID, id))
+	}
+	if traceID, spanID := TraceIDs(ctx); traceID != "" {
+		attrs = append(attrs, slog.String(h.keys.TraceID, traceID))
+		if spanID != "" {
+			attrs = append(attrs, slog.String(h.keys.SpanID, spanID))
+		}
+	}
+	if extra, ok := ctx.Value(attrsContextKey).([]slog.Attr); ok {
+		attrs = append(attrs, extra...)
+	}
+	return attrs
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// The failure handling of the supervisor is tested by the lifecycle package, this
+// tests that the service runs its workers between the start and stop of its components.
+func TestService_Start_Components(t *testing.T) {
+	events := &eventLog{}
+	failing := &mockComponent{name: "queue", events: events, err: make(chan error, 1)}
+	started := make(chan struct{})
+	svc := New(WithOptions(Options{
+		Log: &mockLogger{
+			logs: &[]string{},
+		},
+		Components: []Component{
+			&mockComponent{name: "db", events: events},
+			failing,
+		},
+		Workers: []Worker{{
+			Name: "consumer",
+			Run: func(ctx context.Context) error {
+				events.add("start consumer")
+				close(started)
+				<-ctx.Done()
+				events.add("stop consumer")
+				return nil
+			},
+		}},
+	}))
+
+	go func() {
+		<-started
+		time.Sleep(time.Millisecond * 50)
+		failing.err <- errors.New("crashed")
+	}()
+	if diff := cmp.Diff("queue: crashed", errString(svc.Start())); diff != "" {
+		t.Errorf("Start() = unexpected error (-want +got):\n%s\n", diff)
+	}
+
+	want := []string{"start db", "start queue", "start consumer", "stop consumer", "stop queue", "stop db"}
+	if diff := cmp.Diff(want, events.get()); diff != "" {
+		t.Errorf("Start() = unexpected events (-want +got):\n%s\n", diff)
+	}
+}
+
+// eventLog records the lifecycle events of mock components.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.events
+}
+
+type mockComponent struct {
+	name   string
+	events *eventLog
+	err    chan error
+}
+
+func (c *mockComponent) Name() string {
+	return c.name
+}
+
+func (c *mockComponent) Start(ctx context.Context) error {
+	c.events.add("start " + c.name)
+	return nil
+}
+
+func (c *mockComponent) Stop(ctx context.Context) error {
+	c.events.add("stop " + c.name)
+	return nil
+}
+
+func (c *mockComponent) Err() <-chan error {
+	return c.err
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
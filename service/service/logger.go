@@ -1,17 +1,40 @@
 package service
 
 import (
+	"context"
 	"log/slog"
 	"os"
+
+	"github.com/Zate/go-templates/service/internal/levels"
+	"github.com/Zate/go-templates/service/internal/logctx"
 )
 
-// logger is the interface that wraps around methods Info and Error.
+// logger is the interface that wraps around methods Info and Error, and their
+// variants that log with the request ID and trace ID stored in a context.
 type logger interface {
 	Info(msg string, args ...any)
 	Error(msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
+}
+
+// NewLogger creates a new default logger that logs at the default level of lc, which
+// can be changed at runtime.
+func NewLogger(lc *levels.Controller) logger {
+	return slog.New(logctx.NewHandler(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: lc.Level()}), logctx.DefaultKeys))
+}
+
+// subsystemLogger returns the logger of the subsystem name, that logs with log at the
+// level of the subsystem in lc. A log that is not a *slog.Logger is returned as is.
+func subsystemLogger(log logger, lc *levels.Controller, name string) logger {
+	if l, ok := log.(*slog.Logger); ok {
+		return lc.Logger(l, name)
+	}
+	return log
 }
 
-// NewDefaultLogger creates a new default logger.
+// NewDefaultLogger creates a new default logger, that adds the values of the
+// context of a record with a logctx.Handler.
 func NewDefaultLogger() logger {
-	return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	return slog.New(logctx.NewHandler(slog.NewJSONHandler(os.Stderr, nil), logctx.DefaultKeys))
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/Zate/go-templates/service/internal/logctx"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
@@ -12,7 +13,7 @@ import (
 func TestNewDefaultLogger(t *testing.T) {
 	t.Run("new", func(t *testing.T) {
 		got := NewDefaultLogger()
-		want := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+		want := slog.New(logctx.NewHandler(slog.NewJSONHandler(os.Stderr, nil), logctx.DefaultKeys))
 
 		if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(slog.Logger{})); diff != "" {
 			t.Errorf("NewDefaultLogger() = unexpected result (-want +got):\n%s\n", diff)
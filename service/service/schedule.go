@@ -0,0 +1,287 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next time a job runs after a given time.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Every returns a Schedule that runs every interval d.
+func Every(d time.Duration) Schedule {
+	return everySchedule(d)
+}
+
+// everySchedule runs at a fixed interval.
+type everySchedule time.Duration
+
+// Next returns t plus the interval.
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronDescriptors are the predefined schedules that can be used instead of a cron expression.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseSchedule parses spec into a Schedule in the location loc, or time.Local if loc is nil.
+//
+// spec is a cron expression with five fields (minute, hour, day of month, month and
+// day of week), or six fields with a leading seconds field. Fields accept *, ?, lists,
+// ranges, steps and the names of months and weekdays. A prefix CRON_TZ=<zone> sets the
+// location of the expression. A predefined schedule such as @daily or @hourly can be used
+// instead of an expression, and @every <duration> runs at a fixed interval.
+func ParseSchedule(spec string, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		zone, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		spec = strings.TrimSpace(rest)
+	}
+
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("schedule %q: interval must be positive", spec)
+		}
+		return Every(interval), nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("schedule %q: expected 5 or 6 fields, got %d", spec, len(fields))
+	}
+
+	s := &cronSchedule{loc: loc}
+	var err error
+	for i, f := range []struct {
+		bits   *uint64
+		bounds cronBounds
+	}{
+		{&s.second, secondBounds},
+		{&s.minute, minuteBounds},
+		{&s.hour, hourBounds},
+		{&s.dom, domBounds},
+		{&s.month, monthBounds},
+		{&s.dow, dowBounds},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.bounds); err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+	}
+	s.domStar = fields[3] == "*" || fields[3] == "?"
+	s.dowStar = fields[5] == "*" || fields[5] == "?"
+	// Sunday can be given as both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// cronBounds are the allowed values of a cron field.
+type cronBounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = cronBounds{name: "second", min: 0, max: 59}
+	minuteBounds = cronBounds{name: "minute", min: 0, max: 59}
+	hourBounds   = cronBounds{name: "hour", min: 0, max: 23}
+	domBounds    = cronBounds{name: "day of month", min: 1, max: 31}
+	monthBounds  = cronBounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = cronBounds{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// parseCronField parses a comma separated list of values, ranges and steps into a bit set.
+func parseCronField(field string, bounds cronBounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", bounds.name, stepStr)
+			}
+		}
+
+		var start, end int
+		switch {
+		case expr == "*" || expr == "?":
+			start, end = bounds.min, bounds.max
+		default:
+			lo, hi, isRange := strings.Cut(expr, "-")
+			var err error
+			if start, err = parseCronValue(lo, bounds); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = parseCronValue(hi, bounds); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = bounds.max
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("%s: invalid range %q", bounds.name, expr)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCronValue parses a number or a name within bounds.
+func parseCronValue(s string, bounds cronBounds) (int, error) {
+	if v, ok := bounds.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", bounds.name, s)
+	}
+	if v < bounds.min || v > bounds.max {
+		return 0, fmt.Errorf("%s: value %d out of range [%d, %d]", bounds.name, v, bounds.min, bounds.max)
+	}
+	return v, nil
+}
+
+// cronSchedule is a parsed cron expression, every field is a bit set of the values it matches.
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+	loc                                   *time.Location
+}
+
+// Next returns the first time after t that matches the schedule, in the location of t.
+// It returns the zero time if nothing matches within five years.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	origLoc := t.Location()
+	t = t.In(s.loc)
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+
+	// Every field is advanced until it matches. A field that wraps around moves the
+	// field above it, so matching starts over from the month.
+	added := false
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// Midnight may not exist when daylight saving time starts, move back to the start of the day.
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(-time.Duration(t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t.In(origLoc)
+}
+
+// dayMatches reports if the day of t matches the schedule. When both the day of month
+// and the day of week are restricted, either of them matching is enough.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 20, 30, 500, time.UTC)
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	var tests = []struct {
+		name  string
+		input struct {
+			spec string
+			loc  *time.Location
+		}
+		want struct {
+			next []time.Time
+			err  string
+		}
+	}{
+		{
+			name: "seconds with step",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "*/15 * * * * *", loc: time.UTC},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				next: []time.Time{
+					time.Date(2024, time.January, 31, 10, 20, 45, 0, time.UTC),
+					time.Date(2024, time.January, 31, 10, 21, 0, 0, time.UTC),
+					time.Date(2024, time.January, 31, 10, 21, 15, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "five fields with names",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "30 9 * FEB mon-fri", loc: time.UTC},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				next: []time.Time{
+					time.Date(2024, time.February, 1, 9, 30, 0, 0, time.UTC),
+					time.Date(2024, time.February, 2, 9, 30, 0, 0, time.UTC),
+					time.Date(2024, time.February, 5, 9, 30, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "day of month or day of week",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "0 0 0 1 * 7", loc: time.UTC},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				next: []time.Time{
+					time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+					time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+					time.Date(2024, time.February, 11, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "location",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "0 0 9 * * *", loc: newYork},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				next: []time.Time{
+					time.Date(2024, time.January, 31, 14, 0, 0, 0, time.UTC),
+					time.Date(2024, time.February, 1, 14, 0, 0, 0, time.UTC),
+					time.Date(2024, time.February, 2, 14, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "CRON_TZ prefix",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "CRON_TZ=America/New_York @daily", loc: time.UTC},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				next: []time.Time{
+					time.Date(2024, time.February, 1, 5, 0, 0, 0, time.UTC),
+					time.Date(2024, time.February, 2, 5, 0, 0, 0, time.UTC),
+					time.Date(2024, time.February, 3, 5, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "fixed interval",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "@every 90s"},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				next: []time.Time{
+					from.Add(90 * time.Second),
+					from.Add(180 * time.Second),
+					from.Add(270 * time.Second),
+				},
+			},
+		},
+		{
+			name: "invalid number of fields",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "* * *"},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				err: `schedule "* * *": expected 5 or 6 fields, got 3`,
+			},
+		},
+		{
+			name: "value out of range",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "61 * * * * *"},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				err: `schedule "61 * * * * *": second: value 61 out of range [0, 59]`,
+			},
+		},
+		{
+			name: "invalid interval",
+			input: struct {
+				spec string
+				loc  *time.Location
+			}{spec: "@every -1m"},
+			want: struct {
+				next []time.Time
+				err  string
+			}{
+				err: `schedule "@every -1m": interval must be positive`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(test.input.spec, test.input.loc)
+			if diff := cmp.Diff(test.want.err, errString(err)); diff != "" {
+				t.Fatalf("ParseSchedule(%q) = unexpected error (-want +got):\n%s\n", test.input.spec, diff)
+			}
+			if err != nil {
+				return
+			}
+
+			var got []time.Time
+			next := from
+			for range test.want.next {
+				next = schedule.Next(next)
+				got = append(got, next)
+			}
+			if diff := cmp.Diff(test.want.next, got, cmp.Comparer(time.Time.Equal)); diff != "" {
+				t.Errorf("Next() = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Zate/go-templates/service/internal/logctx"
+)
+
+// Clock tells the time and waits for it to pass. It can be replaced in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock of the system.
+type realClock struct{}
+
+// Now returns the current time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for d to pass and then sends the current time on the returned channel.
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// OverlapPolicy decides what happens when a job is due while its previous run has not finished.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the run.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs the job again once the previous run has finished. At most one
+	// run is queued, runs that are due while one is queued are skipped.
+	OverlapQueue
+	// OverlapAllow runs the job concurrently with the previous run.
+	OverlapAllow
+)
+
+// String returns the name of the overlap policy.
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapQueue:
+		return "queue"
+	case OverlapAllow:
+		return "allow"
+	default:
+		return "skip"
+	}
+}
+
+// Job is a job run by the scheduler of the service.
+type Job struct {
+	// Name identifies the job in logs.
+	Name string
+	// Schedule is a cron expression, a predefined schedule or a fixed interval, see ParseSchedule.
+	Schedule string
+	// Location is the time zone of the schedule, defaults to time.Local. A CRON_TZ prefix
+	// in the schedule takes precedence.
+	Location *time.Location
+	// Jitter delays every run by a random duration up to Jitter, and less than the
+	// time between the run and the next one so that no run is skipped.
+	Jitter time.Duration
+	// Overlap is the policy for a run that is due while the previous run has not finished.
+	Overlap OverlapPolicy
+	// Run does the work. Its context is cancelled if the job has not finished when
+	// the scheduler has drained for the stop timeout.
+	Run func(ctx context.Context) error
+}
+
+// scheduledJob is a job with its parsed schedule and the state of its runs.
+type scheduledJob struct {
+	Job
+	schedule Schedule
+	running  int
+	queued   bool
+}
+
+// scheduler runs jobs on their schedules and implements Component so that it is started
+// and stopped with the service. Stop drains the runs in flight.
+type scheduler struct {
+	jobs     []Job
+	log      logger
+	clock    Clock
+	mu       sync.Mutex
+	rand     *rand.Rand
+	entries  []*scheduledJob
+	stop     chan struct{}
+	stopping bool
+	cancel   context.CancelFunc
+	loops    sync.WaitGroup
+	runs     sync.WaitGroup
+}
+
+// newScheduler returns a scheduler for jobs.
+func newScheduler(jobs []Job, clock Clock) *scheduler {
+	return &scheduler{
+		jobs:  jobs,
+		clock: clock,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Name returns the name of the component.
+func (s *scheduler) Name() string {
+	return "scheduler"
+}
+
+// Start parses the schedules of the jobs and starts scheduling them.
+func (s *scheduler) Start(_ context.Context) error {
+	s.entries = make([]*scheduledJob, 0, len(s.jobs))
+	var errs []error
+	for _, job := range s.jobs {
+		schedule, err := ParseSchedule(job.Schedule, job.Location)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("job %s: %w", job.Name, err))
+			continue
+		}
+		s.entries = append(s.entries, &scheduledJob{Job: job, schedule: schedule})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.stop = make(chan struct{})
+	for _, entry := range s.entries {
+		s.loops.Add(1)
+		go func(entry *scheduledJob) {
+			defer s.loops.Done()
+			s.loop(ctx, entry)
+		}(entry)
+	}
+	return nil
+}
+
+// Stop stops scheduling jobs and waits for the runs in flight to finish. Queued runs
+// are dropped. If ctx is done before the runs have finished, they are cancelled.
+func (s *scheduler) Stop(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	s.loops.Wait()
+
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// loop waits for the next time the job is due and dispatches it, until the scheduler is stopped.
+func (s *scheduler) loop(ctx context.Context, entry *scheduledJob) {
+	next := entry.schedule.Next(s.clock.Now())
+	for !next.IsZero() {
+		jitter := entry.Jitter
+		if after := entry.schedule.Next(next); !after.IsZero() {
+			jitter = min(jitter, after.Sub(next))
+		}
+		delay := next.Sub(s.clock.Now()) + s.jitter(jitter)
+		select {
+		case <-s.stop:
+			return
+		case <-s.clock.After(delay):
+		}
+
+		s.dispatch(ctx, entry)
+
+		now := s.clock.Now()
+		if now.Before(next) {
+			now = next
+		}
+		next = entry.schedule.Next(now)
+	}
+	s.log.Error("Job has no next run.", "job", entry.Name, "schedule", entry.Schedule)
+}
+
+// dispatch runs the job according to its overlap policy.
+func (s *scheduler) dispatch(ctx context.Context, entry *scheduledJob) {
+	s.mu.Lock()
+	if entry.running > 0 {
+		switch entry.Overlap {
+		case OverlapSkip:
+			s.mu.Unlock()
+			s.log.Info("Job skipped.", "job", entry.Name, "outcome", "skipped", "reason", "previous run in progress")
+			return
+		case OverlapQueue:
+			if entry.queued {
+				s.mu.Unlock()
+				s.log.Info("Job skipped.", "job", entry.Name, "outcome", "skipped", "reason", "run already queued")
+				return
+			}
+			entry.queued = true
+			s.mu.Unlock()
+			return
+		}
+	}
+	entry.running++
+	s.runs.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.runs.Done()
+		for {
+			s.execute(ctx, entry)
+
+			s.mu.Lock()
+			if entry.queued && !s.stopping {
+				entry.queued = false
+				s.mu.Unlock()
+				continue
+			}
+			entry.queued = false
+			entry.running--
+			s.mu.Unlock()
+			return
+		}
+	}()
+}
+
+// execute runs the job once and logs its duration and outcome. A panic in the job
+// is recovered and logged as a failure. The name of the job is added to the
+// attributes logged with the context of the run.
+func (s *scheduler) execute(ctx context.Context, entry *scheduledJob) {
+	ctx = logctx.WithAttrs(ctx, slog.String("job", entry.Name))
+	start := s.clock.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				s.log.ErrorContext(ctx, "Job panicked.", "job", entry.Name, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return entry.Run(ctx)
+	}()
+	duration := s.clock.Now().Sub(start).String()
+
+	if err != nil {
+		s.log.ErrorContext(ctx, "Job failed.", "job", entry.Name, "duration", duration, "outcome", "failure", "error", err.Error())
+		return
+	}
+	s.log.InfoContext(ctx, "Job finished.", "job", entry.Name, "duration", duration, "outcome", "success")
+}
+
+// jitter returns a random duration in [0, max).
+func (s *scheduler) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Duration(s.rand.Int63n(int64(max)))
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Zate/go-templates/service/internal/levels"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScheduler_Overlap(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input OverlapPolicy
+		want  struct {
+			runs    int32
+			maxRuns int32
+			skipped bool
+		}
+	}{
+		{
+			name:  "skip",
+			input: OverlapSkip,
+			want: struct {
+				runs    int32
+				maxRuns int32
+				skipped bool
+			}{runs: 1, maxRuns: 1, skipped: true},
+		},
+		{
+			name:  "queue",
+			input: OverlapQueue,
+			want: struct {
+				runs    int32
+				maxRuns int32
+				skipped bool
+			}{runs: 2, maxRuns: 1, skipped: true},
+		},
+		{
+			name:  "allow",
+			input: OverlapAllow,
+			want: struct {
+				runs    int32
+				maxRuns int32
+				skipped bool
+			}{runs: 3, maxRuns: 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var runs, running, maxRuns atomic.Int32
+			release := make(chan struct{})
+			clock := newFakeClock()
+			logs := []string{}
+			log := &mockLogger{logs: &logs}
+
+			s := newScheduler([]Job{{
+				Name:     "report",
+				Schedule: "@every 1s",
+				Overlap:  test.input,
+				Run: func(ctx context.Context) error {
+					runs.Add(1)
+					n := running.Add(1)
+					defer running.Add(-1)
+					for {
+						m := maxRuns.Load()
+						if n <= m || maxRuns.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					<-release
+					return nil
+				},
+			}}, clock)
+			s.log = log
+
+			if err := s.Start(context.Background()); err != nil {
+				t.Fatalf("Start() = unexpected error: %v", err)
+			}
+			// the job is due three times during the first run
+			for i := 0; i < 3; i++ {
+				clock.waitForWaiters(t, 1)
+				clock.Advance(time.Second)
+			}
+			clock.waitForWaiters(t, 1)
+			close(release)
+			for deadline := time.Now().Add(5 * time.Second); runs.Load() < test.want.runs && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := s.Stop(ctx); err != nil {
+				t.Fatalf("Stop() = unexpected error: %v", err)
+			}
+
+			if runs.Load() != test.want.runs {
+				t.Errorf("runs = %d; want %d", runs.Load(), test.want.runs)
+			}
+			if maxRuns.Load() != test.want.maxRuns {
+				t.Errorf("concurrent runs = %d; want %d", maxRuns.Load(), test.want.maxRuns)
+			}
+			if skipped := slices.Contains(log.get(), "Job skipped."); skipped != test.want.skipped {
+				t.Errorf("skipped = %v; want %v", skipped, test.want.skipped)
+			}
+		})
+	}
+}
+
+func TestScheduler_Logs(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input func(ctx context.Context) error
+		want  []string
+	}{
+		{
+			name:  "success",
+			input: func(ctx context.Context) error { return nil },
+			want:  []string{"Job finished.", "job", "report", "duration", "0s", "outcome", "success"},
+		},
+		{
+			name:  "failure",
+			input: func(ctx context.Context) error { return errors.New("boom") },
+			want:  []string{"Job failed.", "job", "report", "duration", "0s", "outcome", "failure", "error", "boom"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := newFakeClock()
+			logs := []string{}
+			log := &mockLogger{logs: &logs}
+			done := make(chan struct{})
+
+			s := newScheduler([]Job{{
+				Name:     "report",
+				Schedule: "0 * * * * *",
+				Run: func(ctx context.Context) error {
+					defer close(done)
+					return test.input(ctx)
+				},
+			}}, clock)
+			s.log = log
+
+			if err := s.Start(context.Background()); err != nil {
+				t.Fatalf("Start() = unexpected error: %v", err)
+			}
+			clock.waitForWaiters(t, 1)
+			clock.Advance(time.Minute)
+			<-done
+			if err := s.Stop(context.Background()); err != nil {
+				t.Fatalf("Stop() = unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, log.get()); diff != "" {
+				t.Errorf("logs = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input time.Duration
+		want  time.Duration
+	}{
+		{name: "jitter", input: 10 * time.Second, want: time.Minute + 10*time.Second},
+		// a delay past the next run would skip it
+		{name: "jitter longer than the schedule", input: 1000 * time.Hour, want: 2 * time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := newFakeClock()
+			s := newScheduler([]Job{{
+				Name:     "report",
+				Schedule: "@every 1m",
+				Jitter:   test.input,
+				Run:      func(ctx context.Context) error { return nil },
+			}}, clock)
+			s.log = &mockLogger{logs: &[]string{}}
+
+			if err := s.Start(context.Background()); err != nil {
+				t.Fatalf("Start() = unexpected error: %v", err)
+			}
+			clock.waitForWaiters(t, 1)
+			got := clock.delays()[0]
+			if err := s.Stop(context.Background()); err != nil {
+				t.Fatalf("Stop() = unexpected error: %v", err)
+			}
+
+			if got < time.Minute || got >= test.want {
+				t.Errorf("delay = %v; want in [1m0s, %v)", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScheduler_Stop(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input struct {
+			jobDuration time.Duration
+			timeout     time.Duration
+		}
+		want struct {
+			err       string
+			cancelled bool
+		}
+	}{
+		{
+			name: "drains runs in flight",
+			input: struct {
+				jobDuration time.Duration
+				timeout     time.Duration
+			}{jobDuration: 50 * time.Millisecond, timeout: time.Second},
+		},
+		{
+			name: "cancels runs after timeout",
+			input: struct {
+				jobDuration time.Duration
+				timeout     time.Duration
+			}{jobDuration: time.Second, timeout: 50 * time.Millisecond},
+			want: struct {
+				err       string
+				cancelled bool
+			}{err: context.DeadlineExceeded.Error(), cancelled: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := newFakeClock()
+			started := make(chan struct{})
+			cancelled := make(chan bool, 1)
+
+			s := newScheduler([]Job{{
+				Name:     "report",
+				Schedule: "@every 1s",
+				Run: func(ctx context.Context) error {
+					close(started)
+					select {
+					case <-time.After(test.input.jobDuration):
+						cancelled <- false
+						return nil
+					case <-ctx.Done():
+						cancelled <- true
+						return ctx.Err()
+					}
+				},
+			}}, clock)
+			s.log = &mockLogger{logs: &[]string{}}
+
+			if err := s.Start(context.Background()); err != nil {
+				t.Fatalf("Start() = unexpected error: %v", err)
+			}
+			clock.waitForWaiters(t, 1)
+			clock.Advance(time.Second)
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), test.input.timeout)
+			defer cancel()
+			err := s.Stop(ctx)
+			if diff := cmp.Diff(test.want.err, errString(err)); diff != "" {
+				t.Errorf("Stop() = unexpected error (-want +got):\n%s\n", diff)
+			}
+			if got := <-cancelled; got != test.want.cancelled {
+				t.Errorf("cancelled = %v; want %v", got, test.want.cancelled)
+			}
+		})
+	}
+}
+
+func TestScheduler_Start_InvalidSchedule(t *testing.T) {
+	s := newScheduler([]Job{{Name: "report", Schedule: "* * *"}}, newFakeClock())
+
+	want := `job report: schedule "* * *": expected 5 or 6 fields, got 3`
+	if diff := cmp.Diff(want, errString(s.Start(context.Background()))); diff != "" {
+		t.Errorf("Start() = unexpected error (-want +got):\n%s\n", diff)
+	}
+}
+
+// fakeClock is a Clock that only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	waited  []time.Duration
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waited = append(c.waited, d)
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the waiters that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// waitForWaiters blocks until n goroutines wait on the clock.
+func (c *fakeClock) waitForWaiters(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		got := len(c.waiters)
+		c.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", n)
+}
+
+// delays returns the durations passed to After.
+func (c *fakeClock) delays() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.waited)
+}
+
+func TestScheduler_Levels(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input func(lc *levels.Controller)
+		want  []string
+	}{
+		{
+			name:  "default level",
+			input: func(lc *levels.Controller) {},
+			want:  []string{"Job finished. scheduler"},
+		},
+		{
+			name: "scheduler overridden",
+			input: func(lc *levels.Controller) {
+				lc.Set(subsystemScheduler, slog.LevelWarn)
+			},
+			want: nil,
+		},
+		{
+			name: "scheduler overridden below the default level",
+			input: func(lc *levels.Controller) {
+				lc.Set("", slog.LevelWarn)
+				lc.Set(subsystemScheduler, slog.LevelInfo)
+			},
+			want: []string{"Job finished. scheduler"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := newFakeClock()
+			lc := levels.NewController(slog.LevelInfo)
+			var buf bytes.Buffer
+			done := make(chan struct{})
+
+			svc := New(WithOptions(Options{
+				Log:    slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: lc.Level()})),
+				Levels: lc,
+				Jobs: []Job{{
+					Name:     "report",
+					Schedule: "0 * * * * *",
+					Run: func(ctx context.Context) error {
+						defer close(done)
+						return nil
+					},
+				}},
+				Clock: clock,
+			}))
+			test.input(lc)
+
+			if err := svc.scheduler.Start(context.Background()); err != nil {
+				t.Fatalf("Start() = unexpected error: %v", err)
+			}
+			clock.waitForWaiters(t, 1)
+			clock.Advance(time.Minute)
+			<-done
+			if err := svc.scheduler.Stop(context.Background()); err != nil {
+				t.Fatalf("Stop() = unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if line == "" {
+					continue
+				}
+				var record map[string]any
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("log = invalid JSON %q: %v", line, err)
+				}
+				got = append(got, fmt.Sprint(record["msg"], " ", record["subsystem"]))
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("logs = unexpected result (-want +got):\n%s\n", diff)
+			}
+		})
+	}
+}
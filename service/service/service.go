@@ -1,20 +1,68 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
+
+	"github.com/Zate/go-templates/service/internal/levels"
+	"github.com/Zate/go-templates/service/internal/lifecycle"
+)
+
+// Defaults for service configuration.
+const (
+	defaultStopTimeout = 15 * time.Second
 )
 
-// service ...
+// subsystemScheduler is the subsystem of the logger of the scheduler, see Options.Levels.
+const subsystemScheduler = "scheduler"
+
+// Component is a part of the service with a lifecycle managed by the service, see
+// lifecycle.Component.
+type Component = lifecycle.Component
+
+// Failer is implemented by components that can fail after they have started.
+// The service shuts down when an error is received on the returned channel.
+type Failer = lifecycle.Failer
+
+// Namer is implemented by components that want to be identified by name
+// in errors and logs.
+type Namer = lifecycle.Namer
+
+// service starts and stops its components.
 type service struct {
-	log logger
+	log         logger
+	levels      *levels.Controller
+	components  []Component
+	workers     *workerPool
+	scheduler   *scheduler
+	stopTimeout time.Duration
 }
 
 // Options holds the configuration for the service.
 type Options struct {
 	Log logger
+	// Levels controls the levels of the default logger, and of the scheduler if Log is
+	// a *slog.Logger, at runtime, e.g. with the handler of Levels.Handler served by a
+	// component. Defaults to a levels.Controller at info level.
+	Levels *levels.Controller
+	// Components are started in order and stopped in reverse order.
+	Components []Component
+	// Workers are supervised by the service. They are started after the
+	// components and stopped before them.
+	Workers []Worker
+	// Jobs are run on their schedules by the scheduler of the service, which is
+	// started after the workers and drains the runs in flight when stopped.
+	Jobs []Job
+	// Clock is used by the scheduler, defaults to the system clock.
+	Clock Clock
+	// StopTimeout is the time each component gets to stop.
+	StopTimeout time.Duration
 }
 
 // Option is a function that configures the service.
@@ -27,47 +75,83 @@ func New(options ...Option) *service {
 		option(s)
 	}
 
+	if s.levels == nil {
+		s.levels = levels.NewController(slog.LevelInfo)
+	}
 	if s.log == nil {
-		s.log = NewDefaultLogger()
+		s.log = NewLogger(s.levels)
+	}
+	if s.stopTimeout == 0 {
+		s.stopTimeout = defaultStopTimeout
+	}
+	if s.workers != nil {
+		s.workers.log = s.log
+	}
+	if s.scheduler != nil {
+		s.scheduler.log = subsystemLogger(s.log, s.levels, subsystemScheduler)
+		if s.scheduler.clock == nil {
+			s.scheduler.clock = realClock{}
+		}
 	}
 
 	return s
 }
 
-// Start the service.
+// Start the service. It starts the components in order and blocks until the
+// process receives a signal or a component fails, and then stops them.
 func (s service) Start() error {
-	errCh := make(chan error, 1)
-	go func() {
-		// Add service startup code here.
-		// Send errors to errCh.
-	}()
+	components := slices.Clip(s.components)
+	if s.workers != nil {
+		components = append(components, s.workers)
+	}
+	if s.scheduler != nil {
+		components = append(components, s.scheduler)
+	}
+	sv := lifecycle.NewSupervisor(components, s.stopTimeout)
 
-	select {
-	case err := <-errCh:
-		s.log.Error("Failed to start service.")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := sv.Start(ctx); err != nil {
+		s.log.Error("Failed to start service.", "error", err.Error())
 		return err
-	case <-time.After(10 * time.Millisecond):
-		// Code for when service start is finsihed.
 	}
+	s.log.Info("Service started.")
 
-	sig, err := s.shutdown()
+	reason, err := s.shutdown(sv)
 	if err != nil {
-		s.log.Error("Failed to shutdown service gracefully.")
+		s.log.Error("Failed to shutdown service gracefully.", "reason", reason, "error", err.Error())
 		return err
 	}
-	s.log.Info("Service shutdown.", "reason", sig.String())
+	s.log.Info("Service shutdown.", "reason", reason)
 	return nil
 }
 
-// shutdown the service.
-func (s service) shutdown() (os.Signal, error) {
+// shutdown the service. It waits for a signal or a component failure, stops
+// the components and returns the reason for the shutdown.
+func (s service) shutdown(sv *lifecycle.Supervisor) (string, error) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-stop
+	defer signal.Stop(stop)
 
-	// Add service shutdown logic here.
+	var reason string
+	var failErr error
+	select {
+	case sig := <-stop:
+		reason = sig.String()
+	case failErr = <-sv.Failed():
+		reason = "component failed"
+	}
 
-	return sig, nil
+	return reason, errors.Join(failErr, sv.Stop())
+}
+
+// WorkerStatus returns the status of the workers, in the order they were given.
+func (s service) WorkerStatus() []WorkerStatus {
+	if s.workers == nil {
+		return nil
+	}
+	return s.workers.Status()
 }
 
 // WithOptions configures the service with the given Options.
@@ -75,5 +159,14 @@ func WithOptions(options Options) Option {
 	return func(s *service) {
 		// Setup on all options from the option struct here.
 		s.log = options.Log
+		s.levels = options.Levels
+		s.components = options.Components
+		if len(options.Workers) > 0 {
+			s.workers = newWorkerPool(options.Workers)
+		}
+		if len(options.Jobs) > 0 {
+			s.scheduler = newScheduler(options.Jobs, options.Clock)
+		}
+		s.stopTimeout = options.StopTimeout
 	}
 }
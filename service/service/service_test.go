@@ -1,11 +1,14 @@
 package service
 
 import (
+	"context"
 	"log/slog"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/Zate/go-templates/service/internal/levels"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
@@ -20,7 +23,9 @@ func TestNew(t *testing.T) {
 			name:  "default",
 			input: []Option{},
 			want: &service{
-				log: NewDefaultLogger(),
+				log:         NewDefaultLogger(),
+				levels:      levels.NewController(slog.LevelInfo),
+				stopTimeout: defaultStopTimeout,
 			},
 		},
 		{
@@ -31,7 +36,9 @@ func TestNew(t *testing.T) {
 				}),
 			},
 			want: &service{
-				log: NewDefaultLogger(),
+				log:         NewDefaultLogger(),
+				levels:      levels.NewController(slog.LevelInfo),
+				stopTimeout: defaultStopTimeout,
 			},
 		},
 	}
@@ -43,7 +50,7 @@ func TestNew(t *testing.T) {
 				t.Errorf("New(%v) = nil; want %v", test.input, test.want)
 			}
 
-			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(service{}), cmpopts.IgnoreUnexported(slog.Logger{})); diff != "" {
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(service{}), cmpopts.IgnoreUnexported(slog.Logger{}, levels.Controller{})); diff != "" {
 				t.Errorf("New(%v) = unexpected result (-want +got):\n%s\n", test.input, diff)
 			}
 		})
@@ -65,6 +72,7 @@ func TestService_Start(t *testing.T) {
 		srv.Start()
 
 		want := []string{
+			"Service started.",
 			"Service shutdown.",
 			"reason",
 			"interrupt",
@@ -77,10 +85,13 @@ func TestService_Start(t *testing.T) {
 }
 
 type mockLogger struct {
+	mu   sync.Mutex
 	logs *[]string
 }
 
 func (l *mockLogger) Info(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	messages := []string{msg}
 	for _, v := range args {
 		messages = append(messages, v.(string))
@@ -89,9 +100,25 @@ func (l *mockLogger) Info(msg string, args ...any) {
 }
 
 func (l *mockLogger) Error(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	messages := []string{msg}
 	for _, v := range args {
 		messages = append(messages, v.(string))
 	}
 	*l.logs = append(*l.logs, messages...)
 }
+
+func (l *mockLogger) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), *l.logs...)
+}
+
+func (l *mockLogger) InfoContext(_ context.Context, msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+func (l *mockLogger) ErrorContext(_ context.Context, msg string, args ...any) {
+	l.Error(msg, args...)
+}
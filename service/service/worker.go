@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Zate/go-templates/service/internal/logctx"
+)
+
+// Defaults for worker configuration.
+const (
+	defaultWorkerBackoff    = time.Second
+	defaultWorkerMaxBackoff = time.Minute
+)
+
+// RestartPolicy decides if a worker is restarted after its run has returned.
+type RestartPolicy int
+
+const (
+	// RestartNever runs the worker once.
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure restarts the worker when its run returns an error or panics,
+	// with an exponential backoff between consecutive failures.
+	RestartOnFailure
+	// RestartAlways restarts the worker whenever its run returns, with an
+	// exponential backoff between consecutive failures.
+	RestartAlways
+)
+
+// String returns the name of the restart policy.
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	default:
+		return "never"
+	}
+}
+
+// WorkerState is the state of a worker.
+type WorkerState string
+
+// States of a worker.
+const (
+	WorkerPending   WorkerState = "pending"
+	WorkerRunning   WorkerState = "running"
+	WorkerBackoff   WorkerState = "backoff"
+	WorkerSucceeded WorkerState = "succeeded"
+	WorkerFailed    WorkerState = "failed"
+	WorkerStopped   WorkerState = "stopped"
+)
+
+// Worker is a unit of work hosted by the service.
+//
+// A long-running loop is a Worker whose Run blocks until ctx is done. A periodic
+// worker has an Interval and its Run is called on every tick. A one-shot job is
+// a Worker whose Run returns when done, with the restart policy RestartNever or
+// RestartOnFailure.
+type Worker struct {
+	// Name identifies the worker in logs and status.
+	Name string
+	// Run does the work. It must return when ctx is done.
+	Run func(ctx context.Context) error
+	// Interval makes the worker periodic, Run is called every Interval until it returns an error.
+	Interval time.Duration
+	// Restart is the restart policy of the worker.
+	Restart RestartPolicy
+	// Backoff is the delay before the first restart after a failure, it is doubled
+	// for every consecutive failure up to MaxBackoff. A run that lasted longer than
+	// the delay resets it to Backoff.
+	Backoff time.Duration
+	// MaxBackoff is the maximum delay between restarts.
+	MaxBackoff time.Duration
+}
+
+// WorkerStatus is the status of a worker.
+type WorkerStatus struct {
+	Name      string
+	State     WorkerState
+	Restarts  int
+	LastError string
+	StartedAt time.Time
+	StoppedAt time.Time
+}
+
+// workerPool runs workers and implements Component so that it is started and
+// stopped with the service.
+type workerPool struct {
+	workers  []Worker
+	log      logger
+	mu       sync.RWMutex
+	statuses []WorkerStatus
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// newWorkerPool returns a workerPool for workers with defaults applied.
+func newWorkerPool(workers []Worker) *workerPool {
+	p := &workerPool{
+		workers:  make([]Worker, len(workers)),
+		statuses: make([]WorkerStatus, len(workers)),
+	}
+	for i, w := range workers {
+		if w.Backoff <= 0 {
+			w.Backoff = defaultWorkerBackoff
+		}
+		if w.MaxBackoff < w.Backoff {
+			w.MaxBackoff = max(defaultWorkerMaxBackoff, w.Backoff)
+		}
+		p.workers[i] = w
+		p.statuses[i] = WorkerStatus{Name: w.Name, State: WorkerPending}
+	}
+	return p
+}
+
+// Name returns the name of the component.
+func (p *workerPool) Name() string {
+	return "workers"
+}
+
+// Start starts all workers in their own goroutine.
+// The workers outlive ctx, they run until Stop is called.
+func (p *workerPool) Start(_ context.Context) error {
+	var ctx context.Context
+	ctx, p.cancel = context.WithCancel(context.Background())
+	for i := range p.workers {
+		p.wg.Add(1)
+		go func(i int) {
+			defer p.wg.Done()
+			p.supervise(ctx, i)
+		}(i)
+	}
+	return nil
+}
+
+// Stop cancels the workers and waits for them to return, or for ctx to be done.
+func (p *workerPool) Stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the status of all workers.
+func (p *workerPool) Status() []WorkerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	statuses := make([]WorkerStatus, len(p.statuses))
+	copy(statuses, p.statuses)
+	return statuses
+}
+
+// supervise runs the worker at index i and restarts it according to its restart policy.
+func (p *workerPool) supervise(ctx context.Context, i int) {
+	w := p.workers[i]
+	backoff := w.Backoff
+
+	for {
+		p.update(i, func(s *WorkerStatus) {
+			s.State = WorkerRunning
+			s.StartedAt = time.Now()
+		})
+		p.log.Info("Worker started.", "worker", w.Name)
+
+		started := time.Now()
+		err := p.run(ctx, w)
+
+		if ctx.Err() != nil {
+			p.update(i, func(s *WorkerStatus) {
+				s.State = WorkerStopped
+				s.StoppedAt = time.Now()
+			})
+			p.log.Info("Worker stopped.", "worker", w.Name)
+			return
+		}
+
+		p.update(i, func(s *WorkerStatus) {
+			s.StoppedAt = time.Now()
+			s.State = WorkerSucceeded
+			if err != nil {
+				s.State = WorkerFailed
+				s.LastError = err.Error()
+			}
+		})
+
+		var delay time.Duration
+		switch {
+		case err != nil && w.Restart != RestartNever:
+			// a run that lasted longer than the backoff is not a consecutive failure
+			if time.Since(started) > backoff {
+				backoff = w.Backoff
+			}
+			p.log.Error("Worker failed.", "worker", w.Name, "error", err.Error(), "restartIn", backoff.String())
+			delay = backoff
+			backoff = min(backoff*2, w.MaxBackoff)
+		case err != nil:
+			p.log.Error("Worker failed.", "worker", w.Name, "error", err.Error())
+			return
+		case w.Restart == RestartAlways:
+			p.log.Info("Worker finished.", "worker", w.Name, "restartIn", w.Backoff.String())
+			delay = w.Backoff
+			backoff = w.Backoff
+		default:
+			p.log.Info("Worker finished.", "worker", w.Name)
+			return
+		}
+
+		p.update(i, func(s *WorkerStatus) {
+			s.State = WorkerBackoff
+			s.Restarts++
+		})
+		select {
+		case <-ctx.Done():
+			p.update(i, func(s *WorkerStatus) {
+				s.State = WorkerStopped
+			})
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// run runs w once, or on every tick until it fails if it is periodic. The name of
+// the worker is added to the attributes logged with the context of its runs.
+// A panic in the worker is recovered and returned as an error.
+func (p *workerPool) run(ctx context.Context, w Worker) (err error) {
+	ctx = logctx.WithAttrs(ctx, slog.String("worker", w.Name))
+	defer func() {
+		if r := recover(); r != nil {
+			p.log.ErrorContext(ctx, "Worker panicked.", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if w.Interval <= 0 {
+		return w.Run(ctx)
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+		}
+	}
+}
+
+// update calls fn with the status of the worker at index i while holding the lock.
+func (p *workerPool) update(i int, fn func(s *WorkerStatus)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(&p.statuses[i])
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestWorkerPool(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input func(runs *atomic.Int32) Worker
+		want  struct {
+			status WorkerStatus
+			runs   int32
+			logs   []string
+		}
+	}{
+		{
+			name: "one-shot succeeds",
+			input: func(runs *atomic.Int32) Worker {
+				return Worker{
+					Name: "migrate",
+					Run: func(ctx context.Context) error {
+						runs.Add(1)
+						return nil
+					},
+				}
+			},
+			want: struct {
+				status WorkerStatus
+				runs   int32
+				logs   []string
+			}{
+				status: WorkerStatus{Name: "migrate", State: WorkerSucceeded},
+				runs:   1,
+			},
+		},
+		{
+			name: "never restarts on failure",
+			input: func(runs *atomic.Int32) Worker {
+				return Worker{
+					Name: "migrate",
+					Run: func(ctx context.Context) error {
+						runs.Add(1)
+						return errors.New("boom")
+					},
+				}
+			},
+			want: struct {
+				status WorkerStatus
+				runs   int32
+				logs   []string
+			}{
+				status: WorkerStatus{Name: "migrate", State: WorkerFailed, LastError: "boom"},
+				runs:   1,
+			},
+		},
+		{
+			name: "restart on failure until it succeeds",
+			input: func(runs *atomic.Int32) Worker {
+				return Worker{
+					Name:    "sync",
+					Restart: RestartOnFailure,
+					Backoff: time.Millisecond,
+					Run: func(ctx context.Context) error {
+						if runs.Add(1) < 3 {
+							return errors.New("boom")
+						}
+						return nil
+					},
+				}
+			},
+			want: struct {
+				status WorkerStatus
+				runs   int32
+				logs   []string
+			}{
+				status: WorkerStatus{Name: "sync", State: WorkerSucceeded, Restarts: 2, LastError: "boom"},
+				runs:   3,
+			},
+		},
+		{
+			name: "always restarts until stopped",
+			input: func(runs *atomic.Int32) Worker {
+				return Worker{
+					Name:    "consumer",
+					Restart: RestartAlways,
+					Backoff: time.Millisecond,
+					Run: func(ctx context.Context) error {
+						if runs.Add(1) < 3 {
+							return nil
+						}
+						<-ctx.Done()
+						return ctx.Err()
+					},
+				}
+			},
+			want: struct {
+				status WorkerStatus
+				runs   int32
+				logs   []string
+			}{
+				status: WorkerStatus{Name: "consumer", State: WorkerStopped, Restarts: 2},
+				runs:   3,
+			},
+		},
+		{
+			name: "panic is recovered and restarted",
+			input: func(runs *atomic.Int32) Worker {
+				return Worker{
+					Name:    "consumer",
+					Restart: RestartOnFailure,
+					Backoff: time.Millisecond,
+					Run: func(ctx context.Context) error {
+						if runs.Add(1) == 1 {
+							panic("oops")
+						}
+						return nil
+					},
+				}
+			},
+			want: struct {
+				status WorkerStatus
+				runs   int32
+				logs   []string
+			}{
+				status: WorkerStatus{Name: "consumer", State: WorkerSucceeded, Restarts: 1, LastError: "panic: oops"},
+				runs:   2,
+				logs:   []string{"Worker panicked.", "panic", "oops"},
+			},
+		},
+		{
+			name: "periodic runs on every tick until it fails",
+			input: func(runs *atomic.Int32) Worker {
+				return Worker{
+					Name:     "cleanup",
+					Interval: time.Millisecond,
+					Run: func(ctx context.Context) error {
+						if runs.Add(1) == 3 {
+							return errors.New("boom")
+						}
+						return nil
+					},
+				}
+			},
+			want: struct {
+				status WorkerStatus
+				runs   int32
+				logs   []string
+			}{
+				status: WorkerStatus{Name: "cleanup", State: WorkerFailed, LastError: "boom"},
+				runs:   3,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs := &atomic.Int32{}
+			logs := []string{}
+			log := &mockLogger{logs: &logs}
+			pool := newWorkerPool([]Worker{test.input(runs)})
+			pool.log = log
+
+			if err := pool.Start(context.Background()); err != nil {
+				t.Fatalf("Start() = unexpected error: %v", err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				status := pool.Status()[0]
+				if status.State == test.want.status.State {
+					break
+				}
+				if test.want.status.State == WorkerStopped && status.State == WorkerRunning && runs.Load() == test.want.runs {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := pool.Stop(ctx); err != nil {
+				t.Errorf("Stop() = unexpected error: %v", err)
+			}
+
+			got := pool.Status()[0]
+			if diff := cmp.Diff(test.want.status, got, cmpopts.IgnoreFields(WorkerStatus{}, "StartedAt", "StoppedAt")); diff != "" {
+				t.Errorf("Status() = unexpected result (-want +got):\n%s\n", diff)
+			}
+			if runs.Load() != test.want.runs {
+				t.Errorf("runs = %d; want %d", runs.Load(), test.want.runs)
+			}
+			if test.want.logs != nil {
+				got := log.get()
+				i := slices.Index(got, test.want.logs[0])
+				if i < 0 || i+len(test.want.logs) > len(got) {
+					t.Fatalf("logs = %v; want to contain %v", got, test.want.logs)
+				}
+				if diff := cmp.Diff(test.want.logs, got[i:i+len(test.want.logs)]); diff != "" {
+					t.Errorf("logs = unexpected result (-want +got):\n%s\n", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestService_WorkerStatus(t *testing.T) {
+	srv := New(WithOptions(Options{
+		Log: NewDefaultLogger(),
+		Workers: []Worker{
+			{Name: "consumer", Run: func(ctx context.Context) error { return nil }},
+			{Name: "cleanup", Interval: time.Minute, Run: func(ctx context.Context) error { return nil }},
+		},
+	}))
+
+	want := []WorkerStatus{
+		{Name: "consumer", State: WorkerPending},
+		{Name: "cleanup", State: WorkerPending},
+	}
+	if diff := cmp.Diff(want, srv.WorkerStatus()); diff != "" {
+		t.Errorf("WorkerStatus() = unexpected result (-want +got):\n%s\n", diff)
+	}
+	if got := New().WorkerStatus(); got != nil {
+		t.Errorf("WorkerStatus() = %v; want nil", got)
+	}
+}
+
+func TestWorkerPool_BackoffReset(t *testing.T) {
+	runs := &atomic.Int32{}
+	logs := []string{}
+	log := &mockLogger{logs: &logs}
+	pool := newWorkerPool([]Worker{{
+		Name:       "consumer",
+		Restart:    RestartOnFailure,
+		Backoff:    10 * time.Millisecond,
+		MaxBackoff: time.Second,
+		Run: func(ctx context.Context) error {
+			switch runs.Add(1) {
+			case 4:
+				// runs for longer than the grown backoff before it fails
+				time.Sleep(200 * time.Millisecond)
+			case 5:
+				return nil
+			}
+			return errors.New("boom")
+		},
+	}})
+	pool.log = log
+
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Start() = unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && pool.Status()[0].State != WorkerSucceeded {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Stop(ctx); err != nil {
+		t.Errorf("Stop() = unexpected error: %v", err)
+	}
+
+	var got []string
+	entries := log.get()
+	for i, entry := range entries {
+		if entry == "restartIn" && i+1 < len(entries) {
+			got = append(got, entries[i+1])
+		}
+	}
+	want := []string{"10ms", "20ms", "40ms", "10ms"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("restartIn = unexpected result (-want +got):\n%s\n", diff)
+	}
+}